@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/helioLJ/tweetvault/config"
@@ -27,11 +28,17 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	// Create bookmark service
+	// Refresh the materialized view shortly after writes to its tables
+	if err := db.Use(services.NewViewRefresher(250*time.Millisecond, 2*time.Second)); err != nil {
+		log.Fatalf("Failed to install view refresher: %v", err)
+	}
+
+	// Create services used by background jobs
 	bookmarkService := services.NewBookmarkService(db)
+	importService := services.NewImportService(db, cfg.ImportDir)
 
 	// Initialize router with custom logging
-	r := routes.SetupRouter(db)
+	r := routes.SetupRouter(db, cfg)
 
 	// Add custom logging middleware
 	r.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
@@ -43,6 +50,7 @@ func main() {
 	}))
 
 	jobs.StartViewRefreshJob(bookmarkService)
+	jobs.StartImportWorker(importService)
 
 	// Start server
 	log.Printf("Server starting on port %s", cfg.ServerPort)
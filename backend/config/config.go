@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/joho/godotenv"
 	"os"
+	"path/filepath"
 )
 
 type Config struct {
@@ -13,6 +14,10 @@ type Config struct {
 	DBPassword string
 	DBName     string
 	ServerPort string
+	ImportDir  string
+	// AutoMigrate applies pending migrations at startup. With
+	// AUTO_MIGRATE=false the server refuses to start until they are run.
+	AutoMigrate bool
 }
 
 func Load() (*Config, error) {
@@ -20,12 +25,19 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("error loading .env file: %w", err)
 	}
 
+	importDir := os.Getenv("IMPORT_DIR")
+	if importDir == "" {
+		importDir = filepath.Join(os.TempDir(), "tweetvault-imports")
+	}
+
 	return &Config{
-		DBHost:     os.Getenv("DB_HOST"),
-		DBPort:     os.Getenv("DB_PORT"),
-		DBUser:     os.Getenv("DB_USER"),
-		DBPassword: os.Getenv("DB_PASSWORD"),
-		DBName:     os.Getenv("DB_NAME"),
-		ServerPort: os.Getenv("SERVER_PORT"),
+		DBHost:      os.Getenv("DB_HOST"),
+		DBPort:      os.Getenv("DB_PORT"),
+		DBUser:      os.Getenv("DB_USER"),
+		DBPassword:  os.Getenv("DB_PASSWORD"),
+		DBName:      os.Getenv("DB_NAME"),
+		ServerPort:  os.Getenv("SERVER_PORT"),
+		ImportDir:   importDir,
+		AutoMigrate: os.Getenv("AUTO_MIGRATE") != "false",
 	}, nil
-}
\ No newline at end of file
+}
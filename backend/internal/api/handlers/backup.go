@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/helioLJ/tweetvault/internal/services"
+	"gorm.io/gorm"
+)
+
+type BackupHandler struct {
+	service   *services.BackupService
+	importDir string
+}
+
+func NewBackupHandler(db *gorm.DB, importDir string) *BackupHandler {
+	return &BackupHandler{service: services.NewBackupService(db), importDir: importDir}
+}
+
+// Backup streams a versioned archive of every bookmark, media file and tag
+func (h *BackupHandler) Backup(c *gin.Context) {
+	fileName := fmt.Sprintf("tweetvault-backup-%s.zip", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	c.Status(http.StatusOK)
+
+	// Headers are already sent once the archive streams, so a failure can only be logged
+	if err := h.service.Write(c.Writer); err != nil {
+		log.Printf("Error writing backup: %v", err)
+	}
+}
+
+// Restore loads an uploaded backup archive. conflict=skip (the default) keeps
+// bookmarks that already exist; conflict=overwrite replaces them, including
+// their tags, completion flags and archive status.
+func (h *BackupHandler) Restore(c *gin.Context) {
+	conflict, err := services.ParseConflictPolicy(c.Query("conflict"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, err := c.FormFile("backup")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No backup file provided"})
+		return
+	}
+
+	// The archive needs random access, so keep it on disk while restoring
+	if err := os.MkdirAll(h.importDir, 0o755); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	dir, err := os.MkdirTemp(h.importDir, "restore-*")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "backup.zip")
+	if err := c.SaveUploadedFile(file, path); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.service.Restore(path, conflict)
+	if errors.Is(err, services.ErrInvalidBackup) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error restoring backup: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore backup"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
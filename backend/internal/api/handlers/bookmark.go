@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"log"
 
 	"github.com/gin-gonic/gin"
 	"github.com/helioLJ/tweetvault/internal/models"
+	"github.com/helioLJ/tweetvault/internal/searchquery"
 	"github.com/helioLJ/tweetvault/internal/services"
 	"gorm.io/gorm"
 )
@@ -25,22 +29,132 @@ func NewBookmarkHandler(db *gorm.DB) *BookmarkHandler {
 
 // List returns all bookmarks with optional filtering
 func (h *BookmarkHandler) List(c *gin.Context) {
-	tag := c.Query("tag")
-	search := c.Query("search")
-	page := c.DefaultQuery("page", "1")
-	limit := c.DefaultQuery("limit", "12")
-	showArchived := c.Query("archived") == "true"
+	filter, ok := bookmarkFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	sort, err := services.ParseSort(c.Query("sort"), c.Query("order"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.respondWithList(c, filter, sort)
+}
+
+// respondWithList writes a page of the bookmarks matching filter, reading
+// the page, cursor and facets parameters from the request
+func (h *BookmarkHandler) respondWithList(c *gin.Context, filter services.BookmarkFilter, sort services.BookmarkSort) {
+	page, err := services.ParseListPage(c.Query("page"), c.Query("limit"), c.Query("after"), c.Query("before"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	bookmarks, total, err := h.service.ListFromView(tag, search, page, limit, showArchived)
+	facetNames, err := services.ParseFacets(c.Query("facets"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.ListFromView(filter, sort, page)
+	if errors.Is(err, services.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"bookmarks": bookmarks,
-		"total":     total,
-	})
+	response := gin.H{
+		"bookmarks":   result.Bookmarks,
+		"total":       result.Total,
+		"next_cursor": nullableString(result.NextCursor),
+		"prev_cursor": nullableString(result.PrevCursor),
+	}
+
+	if len(facetNames) > 0 {
+		facets, err := h.service.Facets(filter, facetNames)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		response["facets"] = facets
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// nullableString turns an empty string into a JSON null
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// Export downloads the bookmarks matching the same filters as List. Only
+// format=csv is supported.
+func (h *BookmarkHandler) Export(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported export format %q", format)})
+		return
+	}
+
+	filter, ok := bookmarkFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	fileName := fmt.Sprintf("tweetvault-bookmarks-%s.csv", time.Now().Format("20060102"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	c.Status(http.StatusOK)
+
+	// Headers are already sent once rows stream, so a failure can only be logged
+	if err := h.service.ExportCSV(c.Writer, filter); err != nil {
+		log.Printf("Error exporting bookmarks: %v", err)
+	}
+}
+
+// bookmarkFilterFromQuery reads the list filters shared by List and Export:
+// tag (repeatable, "name:completed" or "name:pending" to match a completion
+// state), tag_mode=all|any, exclude_tag (repeatable), untagged, search, fuzzy
+// and archived. It writes a 400 response and returns false when they are invalid.
+func bookmarkFilterFromQuery(c *gin.Context) (services.BookmarkFilter, bool) {
+	tagMode, err := services.ParseTagMode(c.Query("tag_mode"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return services.BookmarkFilter{}, false
+	}
+
+	filter := services.BookmarkFilter{
+		Search:   c.Query("search"),
+		Fuzzy:    c.Query("fuzzy") == "true",
+		Archived: c.Query("archived") == "true",
+		TagMode:  tagMode,
+		Untagged: c.Query("untagged") == "true",
+	}
+	for _, tag := range c.QueryArray("tag") {
+		if tag != "" {
+			filter.Tags = append(filter.Tags, services.ParseTagCondition(tag))
+		}
+	}
+	for _, tag := range c.QueryArray("exclude_tag") {
+		if tag != "" {
+			filter.ExcludeTags = append(filter.ExcludeTags, services.ParseTagCondition(tag))
+		}
+	}
+
+	// Reject bad searches up front, before an export starts streaming
+	var syntaxErr *searchquery.Error
+	if _, err := searchquery.Parse(filter.Search); errors.As(err, &syntaxErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": syntaxErr.Error(), "position": syntaxErr.Pos})
+		return services.BookmarkFilter{}, false
+	}
+
+	return filter, true
 }
 
 // Get returns a single bookmark by ID
@@ -64,7 +178,20 @@ func (h *BookmarkHandler) Get(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, bookmark)
+	memberships, err := services.NewListService(h.db).Memberships([]string{bookmark.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	lists := memberships[bookmark.ID]
+	if lists == nil {
+		lists = []models.ListMembership{}
+	}
+	c.JSON(http.StatusOK, struct {
+		models.Bookmark
+		Lists []models.ListMembership `json:"lists"`
+	}{bookmark, lists})
 }
 
 // Update updates a bookmark's tags
@@ -78,7 +205,12 @@ func (h *BookmarkHandler) Update(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.UpdateTags(c.Param("id"), input.Tags); err != nil {
+	err := h.service.UpdateTags(c.Param("id"), input.Tags)
+	if errors.Is(err, services.ErrInvalidTagName) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -127,14 +259,23 @@ func (h *BookmarkHandler) GetStatistics(c *gin.Context) {
 	// Get total tags
 	h.db.Model(&models.Tag{}).Count(&stats.TotalTags)
 
+	// With rollup=true, tag counts include bookmarks with descendant tags
+	with, source := "", `
+		FROM tags t
+		LEFT JOIN bookmark_tags bt ON bt.tag_id = t.id`
+	if c.Query("rollup") == "true" {
+		with, source = services.TagTreeSQL, `
+		FROM tags t
+		JOIN tag_tree tt ON tt.root_id = t.id
+		LEFT JOIN bookmark_tags bt ON bt.tag_id = tt.id`
+	}
+
 	// First get special tags (To do and To read)
-	specialRows, err := h.db.Raw(`
+	specialRows, err := h.db.Raw(with + `
 		SELECT 
 			t.name,
 			COUNT(DISTINCT bt.bookmark_id) as count,
-			COUNT(DISTINCT CASE WHEN bt.completed THEN bt.bookmark_id END) as completed_count
-		FROM tags t
-		LEFT JOIN bookmark_tags bt ON bt.tag_id = t.id
+			COUNT(DISTINCT CASE WHEN bt.completed THEN bt.bookmark_id END) as completed_count` + source + `
 		WHERE t.name IN ('To do', 'To read')
 		GROUP BY t.id, t.name
 	`).Rows()
@@ -165,13 +306,11 @@ func (h *BookmarkHandler) GetStatistics(c *gin.Context) {
 	}
 
 	// Then get top tags (excluding To do and To read)
-	rows, err := h.db.Raw(`
+	rows, err := h.db.Raw(with + `
 		SELECT 
 			t.name,
 			COUNT(DISTINCT bt.bookmark_id) as count,
-			COUNT(DISTINCT CASE WHEN bt.completed THEN bt.bookmark_id END) as completed_count
-		FROM tags t
-		LEFT JOIN bookmark_tags bt ON bt.tag_id = t.id
+			COUNT(DISTINCT CASE WHEN bt.completed THEN bt.bookmark_id END) as completed_count` + source + `
 		WHERE t.name NOT IN ('To do', 'To read')
 		GROUP BY t.id, t.name
 		HAVING COUNT(DISTINCT bt.bookmark_id) > 0
@@ -215,7 +354,7 @@ func (h *BookmarkHandler) ToggleTagCompletion(c *gin.Context) {
 
 	// Find the tag
 	var tag models.Tag
-	if err := h.db.Where("name = ?", tagName).First(&tag).Error; err != nil {
+	if err := h.db.Where("LOWER(name) = LOWER(?)", models.NormalizeTagName(tagName)).First(&tag).Error; err != nil {
 		log.Printf("ToggleTagCompletion: Tag not found: %v", err)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
 		return
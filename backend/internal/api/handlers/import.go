@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/helioLJ/tweetvault/internal/services"
+	"gorm.io/gorm"
+)
+
+type ImportHandler struct {
+	service *services.ImportService
+}
+
+func NewImportHandler(db *gorm.DB, importDir string) *ImportHandler {
+	return &ImportHandler{service: services.NewImportService(db, importDir)}
+}
+
+// List returns the most recent import jobs
+func (h *ImportHandler) List(c *gin.Context) {
+	jobs, err := h.service.List(20)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, jobs)
+}
+
+// Get returns the phase, progress counters and failures of an import job
+func (h *ImportHandler) Get(c *gin.Context) {
+	job, err := h.service.Get(c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// Cancel stops a queued or running import job
+func (h *ImportHandler) Cancel(c *gin.Context) {
+	job, err := h.service.Cancel(c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/helioLJ/tweetvault/internal/services"
+	"gorm.io/gorm"
+)
+
+type ListHandler struct {
+	service *services.ListService
+}
+
+func NewListHandler(db *gorm.DB) *ListHandler {
+	return &ListHandler{service: services.NewListService(db)}
+}
+
+// List returns every list with its bookmark count
+func (h *ListHandler) List(c *gin.Context) {
+	lists, err := h.service.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, lists)
+}
+
+func (h *ListHandler) Get(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+
+	list, err := h.service.Get(id)
+	if err != nil {
+		respondListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *ListHandler) Create(c *gin.Context) {
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	list, err := h.service.Create(input.Name)
+	if err != nil {
+		respondListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, list)
+}
+
+// Update renames a list
+func (h *ListHandler) Update(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	list, err := h.service.Rename(id, input.Name)
+	if err != nil {
+		respondListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// Delete removes a list, keeping its bookmarks
+func (h *ListHandler) Delete(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(id); err != nil {
+		respondListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "List deleted successfully"})
+}
+
+// Bookmarks returns a page of a list's bookmarks in position order
+func (h *ListHandler) Bookmarks(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+
+	page, err := services.ParseListPage(c.Query("page"), c.Query("limit"), "", "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bookmarks, total, err := h.service.Bookmarks(id, page)
+	if err != nil {
+		respondListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bookmarks": bookmarks,
+		"total":     total,
+	})
+}
+
+// AddBookmark puts a bookmark in a list, at the end unless a position is given
+func (h *ListHandler) AddBookmark(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		BookmarkID string `json:"bookmark_id" binding:"required"`
+		Position   *int   `json:"position"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.AddBookmark(id, input.BookmarkID, input.Position); err != nil {
+		respondListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bookmark added to list"})
+}
+
+// MoveBookmark moves a bookmark of a list to a new position
+func (h *ListHandler) MoveBookmark(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Position *int `json:"position" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.MoveBookmark(id, c.Param("bookmarkId"), *input.Position); err != nil {
+		respondListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bookmark moved"})
+}
+
+func (h *ListHandler) RemoveBookmark(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.RemoveBookmark(id, c.Param("bookmarkId")); err != nil {
+		respondListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bookmark removed from list"})
+}
+
+// Reorder sets the order of all of a list's bookmarks
+func (h *ListHandler) Reorder(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		BookmarkIDs []string `json:"bookmark_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Reorder(id, input.BookmarkIDs); err != nil {
+		respondListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "List reordered"})
+}
+
+func listID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "List not found"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondListError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "List not found"})
+	case errors.Is(err, services.ErrBookmarkNotFound), errors.Is(err, services.ErrNotInList):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidList):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
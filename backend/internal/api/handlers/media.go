@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/helioLJ/tweetvault/internal/models"
+	"gorm.io/gorm"
+)
+
+// thumbnailMaxSize is the longest edge, in pixels, of generated photo thumbnails
+const thumbnailMaxSize = 400
+
+type MediaHandler struct {
+	db *gorm.DB
+}
+
+func NewMediaHandler(db *gorm.DB) *MediaHandler {
+	return &MediaHandler{db: db}
+}
+
+// mediaInfo holds everything needed to answer a conditional request without loading the blob
+type mediaInfo struct {
+	ID        uint
+	Type      string
+	FileName  string
+	UpdatedAt time.Time
+	Size      int64
+}
+
+// etag derives a validator from the row identity, modification time and size,
+// which avoids hashing large videos on every range request
+func (m *mediaInfo) etag(variant string) string {
+	return fmt.Sprintf(`"%d-%x-%d%s"`, m.ID, m.UpdatedAt.UnixNano(), m.Size, variant)
+}
+
+// Serve streams the stored media file, supporting Range and conditional requests
+func (h *MediaHandler) Serve(c *gin.Context) {
+	info, ok := h.findMedia(c)
+	if !ok {
+		return
+	}
+
+	etag := info.etag("")
+	setCacheHeaders(c, etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	content := &blobReader{db: h.db, id: info.ID, size: info.Size}
+	contentType, err := mediaContentType(info, content)
+	if err != nil {
+		log.Printf("Error reading media %d: %v", info.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read media"})
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	http.ServeContent(c.Writer, c.Request, info.FileName, info.UpdatedAt, content)
+}
+
+// Thumbnail serves a downscaled JPEG for photos. Videos have no stored poster
+// frame, and clients are never sent to the CDN, whose links break once the
+// tweet is deleted, so they get a 404.
+func (h *MediaHandler) Thumbnail(c *gin.Context) {
+	info, ok := h.findMedia(c)
+	if !ok {
+		return
+	}
+
+	if info.Type != "photo" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail not stored"})
+		return
+	}
+
+	etag := info.etag(fmt.Sprintf("-thumb%d", thumbnailMaxSize))
+	setCacheHeaders(c, etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	data, err := h.loadFileData(info.ID)
+	if err != nil {
+		log.Printf("Error loading media %d: %v", info.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read media"})
+		return
+	}
+
+	thumb, err := makeThumbnail(data, thumbnailMaxSize)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("failed to generate thumbnail: %v", err)})
+		return
+	}
+
+	c.Header("Content-Type", "image/jpeg")
+	http.ServeContent(c.Writer, c.Request, "", info.UpdatedAt, bytes.NewReader(thumb))
+}
+
+// findMedia loads the media metadata and writes an error response when it can't be served
+func (h *MediaHandler) findMedia(c *gin.Context) (*mediaInfo, bool) {
+	var info mediaInfo
+	err := h.db.Model(&models.Media{}).
+		Select("id, type, file_name, updated_at, COALESCE(octet_length(file_data), 0) AS size").
+		Where("id = ?", c.Param("id")).
+		Take(&info).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error finding media %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read media"})
+		return nil, false
+	}
+	if info.Size == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Media file not stored"})
+		return nil, false
+	}
+
+	return &info, true
+}
+
+func (h *MediaHandler) loadFileData(id uint) ([]byte, error) {
+	var media models.Media
+	if err := h.db.Select("file_data").Where("id = ?", id).Take(&media).Error; err != nil {
+		return nil, err
+	}
+	return media.FileData, nil
+}
+
+// blobChunkSize is how much of a media file blobReader fetches per query
+const blobChunkSize = 1 << 20
+
+// blobReader reads a stored media file in chunks, so range requests only
+// transfer the requested part of the blob from the database
+type blobReader struct {
+	db      *gorm.DB
+	id      uint
+	size    int64
+	offset  int64
+	chunk   []byte // Data starting at chunkAt
+	chunkAt int64
+}
+
+func (r *blobReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.offset < r.chunkAt || r.offset >= r.chunkAt+int64(len(r.chunk)) {
+		var chunk []byte
+		// substring positions are 1-based
+		if err := r.db.Model(&models.Media{}).
+			Select("substring(file_data FROM ? FOR ?)", r.offset+1, blobChunkSize).
+			Where("id = ?", r.id).
+			Row().Scan(&chunk); err != nil {
+			return 0, err
+		}
+		if len(chunk) == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		r.chunk, r.chunkAt = chunk, r.offset
+	}
+	n := copy(p, r.chunk[r.offset-r.chunkAt:])
+	r.offset += int64(n)
+	return n, nil
+}
+
+func (r *blobReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek before start of media")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func setCacheHeaders(c *gin.Context, etag string) {
+	c.Header("ETag", etag)
+	// Restores can overwrite stored media, so clients revalidate with the
+	// ETag before reusing their copy
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Accept-Ranges", "bytes")
+}
+
+// etagMatches reports whether an If-None-Match header matches the given strong ETag
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaContentType picks the type from the file name or media type, and only
+// sniffs the start of the file when neither tells
+func mediaContentType(info *mediaInfo, content io.ReadSeeker) (string, error) {
+	if ct := mime.TypeByExtension(strings.ToLower(filepath.Ext(info.FileName))); ct != "" {
+		return ct, nil
+	}
+
+	switch info.Type {
+	case "video", "animated_gif":
+		return "video/mp4", nil
+	case "photo":
+		return "image/jpeg", nil
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(content, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// makeThumbnail decodes an image and re-encodes it as a JPEG no larger than maxSize
+func makeThumbnail(data []byte, maxSize int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, downscale(src, maxSize), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// downscale shrinks an image with a box filter so its longest edge is at most maxSize
+func downscale(src image.Image, maxSize int) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= maxSize && srcH <= maxSize {
+		return src
+	}
+
+	dstW, dstH := maxSize, maxSize
+	if srcW > srcH {
+		dstH = max(1, srcH*maxSize/srcW)
+	} else {
+		dstW = max(1, srcW*maxSize/srcH)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcH/dstH)
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcW/dstW)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(b / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/helioLJ/tweetvault/internal/models"
+	"github.com/helioLJ/tweetvault/internal/services"
+	"gorm.io/gorm"
+)
+
+type SavedSearchHandler struct {
+	service   *services.SavedSearchService
+	bookmarks *BookmarkHandler
+}
+
+func NewSavedSearchHandler(db *gorm.DB) *SavedSearchHandler {
+	return &SavedSearchHandler{
+		service:   services.NewSavedSearchService(db),
+		bookmarks: NewBookmarkHandler(db),
+	}
+}
+
+// savedSearchInput is the body of create and update requests
+type savedSearchInput struct {
+	Name        string   `json:"name" binding:"required"`
+	Search      string   `json:"search"`
+	Fuzzy       bool     `json:"fuzzy"`
+	Tags        []string `json:"tags"`
+	TagMode     string   `json:"tag_mode"`
+	ExcludeTags []string `json:"exclude_tags"`
+	Untagged    bool     `json:"untagged"`
+	Archived    bool     `json:"archived"`
+	Sort        string   `json:"sort"`
+	Order       string   `json:"order"`
+}
+
+func (in *savedSearchInput) model() *models.SavedSearch {
+	return &models.SavedSearch{
+		Name:        in.Name,
+		Search:      in.Search,
+		Fuzzy:       in.Fuzzy,
+		Tags:        in.Tags,
+		TagMode:     in.TagMode,
+		ExcludeTags: in.ExcludeTags,
+		Untagged:    in.Untagged,
+		Archived:    in.Archived,
+		Sort:        in.Sort,
+		Order:       in.Order,
+	}
+}
+
+// List returns the saved searches by name. With counts=true each one
+// includes its current number of matching bookmarks, all counted in one
+// query.
+func (h *SavedSearchHandler) List(c *gin.Context) {
+	searches, err := h.service.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("counts") != "true" {
+		c.JSON(http.StatusOK, searches)
+		return
+	}
+
+	type searchWithCount struct {
+		models.SavedSearch
+		Count int64 `json:"count"`
+	}
+	counts, err := h.service.Counts(searches)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response := make([]searchWithCount, len(searches))
+	for i := range searches {
+		response[i] = searchWithCount{SavedSearch: searches[i], Count: counts[i]}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *SavedSearchHandler) Get(c *gin.Context) {
+	search, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, search)
+}
+
+func (h *SavedSearchHandler) Create(c *gin.Context) {
+	var input savedSearchInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	search := input.model()
+	if err := h.service.Create(search); err != nil {
+		respondSavedSearchError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, search)
+}
+
+// Update replaces the name and filters of a saved search
+func (h *SavedSearchHandler) Update(c *gin.Context) {
+	id, ok := savedSearchID(c)
+	if !ok {
+		return
+	}
+
+	var input savedSearchInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	search, err := h.service.Update(id, input.model())
+	if err != nil {
+		respondSavedSearchError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, search)
+}
+
+func (h *SavedSearchHandler) Delete(c *gin.Context) {
+	id, ok := savedSearchID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(id); err != nil {
+		respondSavedSearchError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Saved search deleted successfully"})
+}
+
+// Bookmarks runs a saved search. The response and the page, limit, after,
+// before and facets parameters are the same as for GET /api/bookmarks.
+func (h *SavedSearchHandler) Bookmarks(c *gin.Context) {
+	search, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	filter, sort, err := services.SavedSearchQuery(search)
+	if err != nil {
+		respondSavedSearchError(c, err)
+		return
+	}
+
+	h.bookmarks.respondWithList(c, filter, sort)
+}
+
+// Count returns the current number of bookmarks matching a saved search
+func (h *SavedSearchHandler) Count(c *gin.Context) {
+	search, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	count, err := h.service.Count(search)
+	if err != nil {
+		respondSavedSearchError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"count": count})
+}
+
+// find loads the saved search named by the id parameter, writing an error
+// response when it can't
+func (h *SavedSearchHandler) find(c *gin.Context) (*models.SavedSearch, bool) {
+	id, ok := savedSearchID(c)
+	if !ok {
+		return nil, false
+	}
+
+	search, err := h.service.Get(id)
+	if err != nil {
+		respondSavedSearchError(c, err)
+		return nil, false
+	}
+	return search, true
+}
+
+func savedSearchID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondSavedSearchError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+	case errors.Is(err, services.ErrInvalidSavedSearch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSavedSearchExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/helioLJ/tweetvault/internal/services"
+	"gorm.io/gorm"
+)
+
+type SearchHandler struct {
+	service *services.BookmarkService
+}
+
+func NewSearchHandler(db *gorm.DB) *SearchHandler {
+	return &SearchHandler{service: services.NewBookmarkService(db)}
+}
+
+// Suggest returns tags, authors and hashtags matching the partial term q,
+// for autocomplete as the user types
+func (h *SearchHandler) Suggest(c *gin.Context) {
+	q := c.Query("q")
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	limit, err := services.ParseSuggestLimit(c.Query("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	suggestions, err := h.service.Suggest(q, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, suggestions)
+}
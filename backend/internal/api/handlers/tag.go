@@ -1,26 +1,31 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/helioLJ/tweetvault/internal/models"
+	"github.com/helioLJ/tweetvault/internal/services"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
 )
 
 type TagHandler struct {
-	db *gorm.DB
+	db      *gorm.DB
+	service *services.TagService
 }
 
 var standardTags = []string{"To do", "To read"}
 
 func NewTagHandler(db *gorm.DB) *TagHandler {
-	return &TagHandler{db: db}
+	return &TagHandler{db: db, service: services.NewTagService(db)}
 }
 
 func (h *TagHandler) List(c *gin.Context) {
 	var tags []models.Tag
-	if err := h.db.Find(&tags).Error; err != nil {
+	if err := h.db.Preload("Aliases").Order(services.TagOrder).Find(&tags).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -28,9 +33,13 @@ func (h *TagHandler) List(c *gin.Context) {
 	c.JSON(http.StatusOK, tags)
 }
 
+// Update renames a tag and replaces its metadata. Standard tags keep their
+// name but can still be styled.
 func (h *TagHandler) Update(c *gin.Context) {
+	// Only the fields present are changed, so renaming keeps the metadata
 	var input struct {
-		Name string `json:"name" binding:"required"`
+		Name *string `json:"name"`
+		services.TagMetadataUpdate
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -44,14 +53,27 @@ func (h *TagHandler) Update(c *gin.Context) {
 		return
 	}
 
-	if isStandardTag(tag.Name) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot rename standard tags"})
+	if input.Name != nil {
+		name, ok := h.checkName(c, *input.Name, tag.ID)
+		if !ok {
+			return
+		}
+		if isStandardTag(tag.Name) && name != tag.Name {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot rename standard tags"})
+			return
+		}
+		tag.Name = name
+	}
+
+	metadata, err := input.Apply(tag.TagMetadata)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	tag.Name = input.Name
+	tag.TagMetadata = metadata
 	if err := h.db.Save(&tag).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondTagWriteError(c, err)
 		return
 	}
 
@@ -73,6 +95,13 @@ func (h *TagHandler) Delete(c *gin.Context) {
 	// Start a transaction
 	tx := h.db.Begin()
 
+	// Move the tag's children up to its parent
+	if err := tx.Model(&models.Tag{}).Where("parent_id = ?", tag.ID).Update("parent_id", tag.ParentID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Delete the tag associations from bookmark_tags
 	if err := tx.Exec("DELETE FROM bookmark_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
 		tx.Rollback()
@@ -115,7 +144,9 @@ func (h *TagHandler) GetBookmarkCount(c *gin.Context) {
 
 func (h *TagHandler) Create(c *gin.Context) {
 	var input struct {
-		Name string `json:"name" binding:"required"`
+		Name     string `json:"name" binding:"required"`
+		ParentID *uint  `json:"parent_id"`
+		models.TagMetadata
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -123,9 +154,25 @@ func (h *TagHandler) Create(c *gin.Context) {
 		return
 	}
 
-	tag := models.Tag{Name: input.Name}
+	name, ok := h.checkName(c, input.Name, 0)
+	if !ok {
+		return
+	}
+
+	metadata, err := services.ValidateTagMetadata(input.TagMetadata)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.CheckParent(input.ParentID); err != nil {
+		respondTagParentError(c, err)
+		return
+	}
+
+	tag := models.Tag{Name: name, ParentID: input.ParentID, TagMetadata: metadata}
 	if err := h.db.Create(&tag).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondTagWriteError(c, err)
 		return
 	}
 
@@ -136,6 +183,137 @@ func (h *TagHandler) Create(c *gin.Context) {
 	c.JSON(http.StatusCreated, tag)
 }
 
+// Tree returns the tags as a tree with direct and rolled-up bookmark counts
+func (h *TagHandler) Tree(c *gin.Context) {
+	tree, err := h.service.Tree()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tree)
+}
+
+// Move puts a tag and its descendants under another tag, or at the root
+// when parent_id is null
+func (h *TagHandler) Move(c *gin.Context) {
+	var input struct {
+		ParentID *uint `json:"parent_id"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	tag, err := h.service.Move(uint(id), input.ParentID)
+	if err != nil {
+		respondTagParentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// Merge moves the bookmarks of a tag into target_id and deletes the tag.
+// With alias, its name keeps working as an alias of the target.
+func (h *TagHandler) Merge(c *gin.Context) {
+	var input struct {
+		TargetID uint `json:"target_id" binding:"required"`
+		Alias    bool `json:"alias"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var source models.Tag
+	if err := h.db.First(&source, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	if isStandardTag(source.Name) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot merge standard tags into other tags"})
+		return
+	}
+
+	target, err := h.service.Merge(source.ID, input.TargetID, input.Alias)
+	if errors.Is(err, services.ErrInvalidMerge) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		respondTagParentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, target)
+}
+
+// checkName normalizes a new name for the tag with the given ID (0 for a
+// new tag) and checks that no other tag or alias has it, ignoring case. It
+// writes an error response and returns false when the name can't be used.
+func (h *TagHandler) checkName(c *gin.Context, name string, id uint) (string, bool) {
+	name, err := services.ValidateTagName(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+
+	var count int64
+	if err := h.db.Model(&models.Tag{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, id).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A tag with this name already exists"})
+		return "", false
+	}
+
+	if err := h.db.Model(&models.TagAlias{}).Where("LOWER(name) = LOWER(?)", name).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tag name is an alias of another tag"})
+		return "", false
+	}
+
+	return name, true
+}
+
+// respondTagWriteError answers a failed tag insert or update. checkName
+// runs before the write, so a concurrent request can still take the name
+// first; the unique index then rejects the write and it gets the same 409.
+func respondTagWriteError(c *gin.Context, err error) {
+	// 23505 is unique_violation
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_tags_name_lower" {
+		c.JSON(http.StatusConflict, gin.H{"error": "A tag with this name already exists"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+func respondTagParentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+	case errors.Is(err, services.ErrInvalidTagParent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
 func isStandardTag(tagName string) bool {
 	for _, st := range standardTags {
 		if st == tagName {
@@ -1,307 +1,93 @@
 package handlers
 
 import (
-	"encoding/json"
-	"fmt"
-	"io"
-	"mime/multipart"
-	"net/http"
-	"os"
-	"strconv"
-	"time"
-
-	"archive/zip"
-
 	"errors"
+	"log"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/helioLJ/tweetvault/internal/models"
+	"github.com/helioLJ/tweetvault/internal/importers"
+	"github.com/helioLJ/tweetvault/internal/services"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 type UploadHandler struct {
-	db *gorm.DB
-}
-
-func NewUploadHandler(db *gorm.DB) *UploadHandler {
-	return &UploadHandler{db: db}
+	service *services.ImportService
 }
 
-type TwitterBookmark struct {
-	ID              string          `json:"id"`
-	CreatedAt       string          `json:"created_at"`
-	FullText        string          `json:"full_text"`
-	ScreenName      string          `json:"screen_name"`
-	Name            string          `json:"name"`
-	ProfileImageURL string          `json:"profile_image_url"`
-	InReplyTo       *string         `json:"in_reply_to"`
-	RetweetedStatus *string         `json:"retweeted_status"`
-	QuotedStatus    *string         `json:"quoted_status"`
-	FavoriteCount   int             `json:"favorite_count"`
-	RetweetCount    int             `json:"retweet_count"`
-	BookmarkCount   int             `json:"bookmark_count"`
-	QuoteCount      int             `json:"quote_count"`
-	ReplyCount      int             `json:"reply_count"`
-	ViewsCount      int             `json:"views_count"`
-	Favorited       bool            `json:"favorited"`
-	Retweeted       bool            `json:"retweeted"`
-	Bookmarked      bool            `json:"bookmarked"`
-	URL             string          `json:"url"`
-	Metadata        json.RawMessage `json:"metadata"`
-	Media           []struct {
-		Type      string `json:"type"`
-		URL       string `json:"url"`
-		Thumbnail string `json:"thumbnail"`
-		Original  string `json:"original"`
-	} `json:"media"`
+func NewUploadHandler(db *gorm.DB, importDir string) *UploadHandler {
+	return &UploadHandler{service: services.NewImportService(db, importDir)}
 }
 
+// HandleUpload stores the uploaded export and queues it as an import job.
+// Progress and the final report are available through GET /api/imports/:id.
+// With dry_run=true the job only reports what the import would change, and
+// merge_policy decides how bookmarks that already exist are treated. The
+// importer is detected from the files unless format names one explicitly.
+// CSV uploads may pass csv_mapping, a JSON object mapping headers to fields.
 func (h *UploadHandler) HandleUpload(c *gin.Context) {
-	// Get the JSON file
-	jsonFile, err := c.FormFile("jsonFile")
-	if err != nil {
-		fmt.Printf("Error getting JSON file: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No JSON file provided"})
+	// Get the data file (JSON or CSV), which X archives don't have.
+	// jsonFile is the original field name and still accepted.
+	dataFile, err := c.FormFile("dataFile")
+	if errors.Is(err, http.ErrMissingFile) {
+		dataFile, err = c.FormFile("jsonFile")
+	}
+	if errors.Is(err, http.ErrMissingFile) {
+		dataFile = nil
+	} else if err != nil {
+		log.Printf("Error getting data file: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data file"})
 		return
 	}
 
-	// Get the ZIP file
+	// Get the ZIP file, which exports without media don't need
 	zipFile, err := c.FormFile("zipFile")
-	if err != nil {
-		fmt.Printf("Error getting ZIP file: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No ZIP file provided"})
+	if errors.Is(err, http.ErrMissingFile) {
+		zipFile = nil
+	} else if err != nil {
+		log.Printf("Error getting ZIP file: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ZIP file"})
 		return
 	}
 
-	// Process JSON file
-	bookmarks, err := h.processJSONFile(jsonFile)
-	if err != nil {
-		fmt.Printf("Error processing JSON file: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if dataFile == nil && zipFile == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
 		return
 	}
 
-	// Begin transaction
-	tx := h.db.Begin()
-
-	// Process each bookmark
-	for i, bookmark := range bookmarks {
-		// Create or update bookmark
-		if err := h.processBookmark(tx, bookmark, zipFile); err != nil {
-			fmt.Printf("Error processing bookmark %d: %v\n", i, err)
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-	}
-
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		fmt.Printf("Error committing transaction: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Upload processed successfully",
-		"count":   len(bookmarks),
-	})
-}
-
-func (h *UploadHandler) processJSONFile(file *multipart.FileHeader) ([]TwitterBookmark, error) {
-	src, err := file.Open()
-	if err != nil {
-		return nil, err
-	}
-	defer src.Close()
-
-	bytes, err := io.ReadAll(src)
+	mergePolicy, err := services.ParseMergePolicy(c.Query("merge_policy"))
 	if err != nil {
-		return nil, err
-	}
-
-	var bookmarks []TwitterBookmark
-	if err := json.Unmarshal(bytes, &bookmarks); err != nil {
-		return nil, err
-	}
-
-	return bookmarks, nil
-}
-
-func (h *UploadHandler) processBookmark(tx *gorm.DB, tb TwitterBookmark, zipFile *multipart.FileHeader) error {
-	bookmark := models.Bookmark{
-		ID:              tb.ID,
-		CreatedAt:       parseTwitterTime(tb.CreatedAt),
-		FullText:        tb.FullText,
-		ScreenName:      tb.ScreenName,
-		Name:            tb.Name,
-		ProfileImageURL: tb.ProfileImageURL,
-		FavoriteCount:   tb.FavoriteCount,
-		RetweetCount:    tb.RetweetCount,
-		BookmarkCount:   tb.BookmarkCount,
-		QuoteCount:      tb.QuoteCount,
-		ReplyCount:      tb.ReplyCount,
-		ViewsCount:      tb.ViewsCount,
-		Favorited:       tb.Favorited,
-		Retweeted:       tb.Retweeted,
-		Bookmarked:      tb.Bookmarked,
-		URL:             tb.URL,
-		Metadata:        tb.Metadata,
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	// Create or update bookmark
-	if err := tx.Save(&bookmark).Error; err != nil {
-		return err
+	opts := services.ImportOptions{
+		DryRun:      c.Query("dry_run") == "true",
+		MergePolicy: mergePolicy,
+		Format:      c.Query("format"),
+		CSVMapping:  c.DefaultPostForm("csv_mapping", c.Query("csv_mapping")),
 	}
 
-	// Process media files from ZIP
-	for i, m := range tb.Media {
-		mediaFileName := generateMediaFileName(tb.ScreenName, tb.ID, m.Type, i+1)
-
-		// Check if media already exists - use Session to temporarily disable error logging
-		var existingMedia models.Media
-		err := tx.Session(&gorm.Session{
-			Logger: tx.Logger.LogMode(logger.Silent),
-		}).Where("tweet_id = ? AND file_name = ?", tb.ID, mediaFileName).
-			First(&existingMedia).Error
-		
-		if err == nil {
-			// Media already exists, skip creating a new one
-			continue
-		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-			// Only return error if it's not a "record not found" error
-			return fmt.Errorf("error checking existing media: %w", err)
-		}
-
-		// Media doesn't exist, proceed with creation
-		mediaData, err := extractFileFromZip(zipFile, mediaFileName)
-		if err != nil {
-			return fmt.Errorf("failed to extract media file %s: %w", mediaFileName, err)
-		}
-
-		// Skip creating media record if no data was found in ZIP
-		if mediaData == nil {
-			continue
-		}
-
-		media := models.Media{
-			TweetID:   tb.ID,
-			Type:      m.Type,
-			URL:       m.URL,
-			Thumbnail: m.Thumbnail,
-			Original:  m.Original,
-			FileData:  mediaData,
-			FileName:  mediaFileName,
-		}
-
-		if err := tx.Create(&media).Error; err != nil {
-			return fmt.Errorf("failed to create media record: %w", err)
+	if opts.CSVMapping != "" {
+		if err := importers.ValidateCSVMapping(opts.CSVMapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 	}
 
-	return nil
-}
-
-// Helper functions (to be implemented)
-func parseTwitterTime(timeStr string) time.Time {
-	// Try parsing with timezone offset format (with space before timezone)
-	t, err := time.Parse("2006-01-02 15:04:05 -0700", timeStr)
-	if err == nil {
-		return t
-	}
-
-	// Try parsing with timezone offset format (without space)
-	t, err = time.Parse("2006-01-02 15:04:05-0700", timeStr)
-	if err == nil {
-		return t
-	}
-
-	// Try parsing with timezone offset format (with space and colon in timezone)
-	t, err = time.Parse("2006-01-02 15:04:05 -07:00", timeStr)
-	if err == nil {
-		return t
-	}
-
-	// Fallback to parsing without timezone
-	t, err = time.Parse("2006-01-02 15:04:05", timeStr)
-	if err == nil {
-		return t
-	}
-
-	// If all parsing attempts fail, log the error and return current time
-	fmt.Printf("Error parsing time '%s': %v\n", timeStr, err)
-	return time.Now()
-}
-
-func generateMediaFileName(screenName, tweetID, mediaType string, index int) string {
-	// Extract creation date from tweet ID to match the file naming convention
-	// Twitter IDs contain a timestamp that we can use
-	tweetIDInt, _ := strconv.ParseInt(tweetID, 10, 64)
-	timestamp := time.Unix((tweetIDInt>>22)/1000+1288834974657/1000, 0)
-	dateStr := timestamp.Format("20060102") // Format as YYYYMMDD
-
-	return fmt.Sprintf("%s_%s_%s_%d_%s%s",
-		screenName,
-		tweetID,
-		mediaType,
-		index,
-		dateStr,
-		getExtensionForMediaType(mediaType))
-}
-
-func getExtensionForMediaType(mediaType string) string {
-	switch mediaType {
-	case "video":
-		return ".mp4"
-	case "photo":
-		return ".jpg"
-	default:
-		return ""
-	}
-}
-
-func extractFileFromZip(zipFile *multipart.FileHeader, fileName string) ([]byte, error) {
-	// Open the uploaded zip file
-	src, err := zipFile.Open()
-	if err != nil {
-		return nil, fmt.Errorf("failed to open zip file: %w", err)
-	}
-	defer src.Close()
-
-	// Create a temporary file to store the zip content
-	tempFile, err := os.CreateTemp("", "upload-*.zip")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
-	}
-	defer os.Remove(tempFile.Name())
-	defer tempFile.Close()
-
-	// Copy zip content to temp file
-	if _, err := io.Copy(tempFile, src); err != nil {
-		return nil, fmt.Errorf("failed to copy zip content: %w", err)
+	job, err := h.service.CreateJob(dataFile, zipFile, opts)
+	if errors.Is(err, importers.ErrUnrecognized) || errors.Is(err, services.ErrUnknownFormat) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-
-	// Open the zip archive
-	reader, err := zip.OpenReader(tempFile.Name())
 	if err != nil {
-		return nil, fmt.Errorf("failed to open zip archive: %w", err)
-	}
-	defer reader.Close()
-
-	// Remove debug logging and directly search for the file
-	for _, file := range reader.File {
-		if file.Name == fileName {
-			rc, err := file.Open()
-			if err != nil {
-				return nil, fmt.Errorf("failed to open file in zip: %w", err)
-			}
-			defer rc.Close()
-
-			return io.ReadAll(rc)
-		}
+		log.Printf("Error creating import job: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue import"})
+		return
 	}
 
-	// File not found - return nil without error to allow processing to continue
-	return nil, nil
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Upload queued for import",
+		"import":  job,
+	})
 }
@@ -4,11 +4,13 @@ import (
 	"log"
 
 	"github.com/gin-gonic/gin"
+	"github.com/helioLJ/tweetvault/config"
 	"github.com/helioLJ/tweetvault/internal/api/handlers"
+	"github.com/helioLJ/tweetvault/internal/services"
 	"gorm.io/gorm"
 )
 
-func SetupRouter(db *gorm.DB) *gin.Engine {
+func SetupRouter(db *gorm.DB, cfg *config.Config) *gin.Engine {
 	r := gin.New()
 
 	// Add recovery middleware
@@ -30,7 +32,7 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 	// Enable CORS
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, DELETE, OPTIONS")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 
@@ -42,10 +44,23 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 		c.Next()
 	})
 
+	// Routes that read the materialized view wait once for recent writes
+	// to reach it, so a change shows up in the next list
+	freshView := func(c *gin.Context) {
+		services.WaitForView(db)
+		c.Next()
+	}
+
 	// Create handler instances
-	uploadHandler := handlers.NewUploadHandler(db)
+	uploadHandler := handlers.NewUploadHandler(db, cfg.ImportDir)
+	importHandler := handlers.NewImportHandler(db, cfg.ImportDir)
 	bookmarkHandler := handlers.NewBookmarkHandler(db)
 	tagHandler := handlers.NewTagHandler(db)
+	mediaHandler := handlers.NewMediaHandler(db)
+	backupHandler := handlers.NewBackupHandler(db, cfg.ImportDir)
+	savedSearchHandler := handlers.NewSavedSearchHandler(db)
+	searchHandler := handlers.NewSearchHandler(db)
+	listHandler := handlers.NewListHandler(db)
 
 	// API routes
 	api := r.Group("/api")
@@ -58,19 +73,64 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 		// Upload endpoints
 		api.POST("/upload", uploadHandler.HandleUpload)
 
+		// Import job endpoints
+		api.GET("/imports", importHandler.List)
+		api.GET("/imports/:id", importHandler.Get)
+		api.POST("/imports/:id/cancel", importHandler.Cancel)
+
 		// Bookmark endpoints
-		api.GET("/bookmarks", bookmarkHandler.List)
+		api.GET("/bookmarks", freshView, bookmarkHandler.List)
 		api.GET("/bookmarks/:id", bookmarkHandler.Get)
 		api.PUT("/bookmarks/:id", bookmarkHandler.Update)
 		api.DELETE("/bookmarks/:id", bookmarkHandler.Delete)
 		api.POST("/bookmarks/:id/toggle-archive", bookmarkHandler.ToggleArchive)
 		api.POST("/bookmarks/:id/tags/:tagName/toggle-completion", bookmarkHandler.ToggleTagCompletion)
 
+		// Search endpoints
+		api.GET("/search/suggest", freshView, searchHandler.Suggest)
+
+		// Saved search endpoints
+		api.GET("/saved-searches", freshView, savedSearchHandler.List)
+		api.POST("/saved-searches", savedSearchHandler.Create)
+		api.GET("/saved-searches/:id", savedSearchHandler.Get)
+		api.PUT("/saved-searches/:id", savedSearchHandler.Update)
+		api.DELETE("/saved-searches/:id", savedSearchHandler.Delete)
+		api.GET("/saved-searches/:id/bookmarks", freshView, savedSearchHandler.Bookmarks)
+		api.GET("/saved-searches/:id/count", freshView, savedSearchHandler.Count)
+
+		// List endpoints
+		api.GET("/lists", listHandler.List)
+		api.POST("/lists", listHandler.Create)
+		api.GET("/lists/:id", listHandler.Get)
+		api.PUT("/lists/:id", listHandler.Update)
+		api.DELETE("/lists/:id", listHandler.Delete)
+		api.GET("/lists/:id/bookmarks", freshView, listHandler.Bookmarks)
+		api.POST("/lists/:id/bookmarks", listHandler.AddBookmark)
+		api.PUT("/lists/:id/bookmarks/:bookmarkId", listHandler.MoveBookmark)
+		api.DELETE("/lists/:id/bookmarks/:bookmarkId", listHandler.RemoveBookmark)
+		api.PUT("/lists/:id/order", listHandler.Reorder)
+
+		// Export endpoint
+		api.GET("/export", freshView, bookmarkHandler.Export)
+
+		// Backup endpoints
+		api.GET("/backup", backupHandler.Backup)
+		api.POST("/restore", backupHandler.Restore)
+
+		// Media endpoints
+		api.GET("/media/:id", mediaHandler.Serve)
+		api.HEAD("/media/:id", mediaHandler.Serve)
+		api.GET("/media/:id/thumbnail", mediaHandler.Thumbnail)
+		api.HEAD("/media/:id/thumbnail", mediaHandler.Thumbnail)
+
 		// Tag endpoints
 		api.GET("/tags", tagHandler.List)
+		api.GET("/tags/tree", tagHandler.Tree)
 		api.POST("/tags", tagHandler.Create)
 		api.PUT("/tags/:id", tagHandler.Update)
 		api.DELETE("/tags/:id", tagHandler.Delete)
+		api.PUT("/tags/:id/parent", tagHandler.Move)
+		api.POST("/tags/:id/merge", tagHandler.Merge)
 		api.GET("/tags/:id/count", tagHandler.GetBookmarkCount)
 
 		// Statistics endpoint
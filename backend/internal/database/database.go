@@ -13,29 +13,38 @@ import (
 // Fixed tags that should always exist in the system
 var standardTags = []string{"To do", "To read"}
 
-func Connect(cfg *config.Config) (*gorm.DB, error) {
+// Open connects to the database without checking or migrating the schema
+func Open(cfg *config.Config) (*gorm.DB, error) {
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
 		cfg.DBHost, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBPort)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{})
+}
+
+// Connect opens the database for the server. It refuses schemas migrated by
+// a newer build, applies pending migrations unless AutoMigrate is off, and
+// seeds the standard tags.
+func Connect(cfg *config.Config) (*gorm.DB, error) {
+	db, err := Open(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	// Auto-migrate the schema
-	err = db.AutoMigrate(
-		&models.Bookmark{},
-		&models.Media{},
-		&models.Tag{},
-		&models.BookmarkTag{},
-	)
+	pending, err := CheckSchema(db)
 	if err != nil {
 		return nil, err
 	}
-
-	// Create materialized view
-	if err := CreateBookmarkView(db); err != nil {
-		log.Printf("Warning: Failed to create materialized view: %v", err)
+	if pending > 0 {
+		if !cfg.AutoMigrate {
+			return nil, fmt.Errorf("%w: run the migrate command with up", ErrPendingMigrations)
+		}
+		applied, err := MigrateUp(db)
+		for _, m := range applied {
+			log.Printf("Applied migration %d (%s)", m.Version, m.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Ensure standard tags exist
@@ -43,18 +52,30 @@ func Connect(cfg *config.Config) (*gorm.DB, error) {
 		return nil, err
 	}
 
-	// Add join table configuration
-	db.SetupJoinTable(&models.Bookmark{}, "Tags", &models.BookmarkTag{})
-	db.SetupJoinTable(&models.Tag{}, "Bookmarks", &models.BookmarkTag{})
+	if err := SetupJoinTables(db); err != nil {
+		return nil, err
+	}
 
 	return db, nil
 }
 
+// SetupJoinTables registers the join models that carry extra columns, such
+// as tag completion and list positions
+func SetupJoinTables(db *gorm.DB) error {
+	if err := db.SetupJoinTable(&models.Bookmark{}, "Tags", &models.BookmarkTag{}); err != nil {
+		return err
+	}
+	if err := db.SetupJoinTable(&models.Tag{}, "Bookmarks", &models.BookmarkTag{}); err != nil {
+		return err
+	}
+	return db.SetupJoinTable(&models.List{}, "Bookmarks", &models.ListBookmark{})
+}
+
 // ensureStandardTags creates the standard tags if they don't exist
 func ensureStandardTags(db *gorm.DB) error {
 	for _, tagName := range standardTags {
 		var tag models.Tag
-		result := db.Where("name = ?", tagName).First(&tag)
+		result := db.Where("LOWER(name) = LOWER(?)", tagName).First(&tag)
 		if result.Error == gorm.ErrRecordNotFound {
 			// Create the tag if it doesn't exist
 			tag = models.Tag{Name: tagName}
@@ -67,59 +88,3 @@ func ensureStandardTags(db *gorm.DB) error {
 	}
 	return nil
 }
-
-// Move CreateBookmarkView here from migrations/create_bookmark_view.go
-func CreateBookmarkView(db *gorm.DB) error {
-	// Drop existing view if it exists
-	db.Exec(`DROP MATERIALIZED VIEW IF EXISTS bookmark_views`)
-
-	// Create the materialized view
-	return db.Exec(`
-		CREATE MATERIALIZED VIEW bookmark_views AS
-		SELECT 
-			b.id,
-			b.created_at,
-			b.full_text,
-			b.screen_name,
-			b.name,
-			b.profile_image_url,
-			b.favorite_count,
-			b.retweet_count,
-			b.views_count,
-			b.url,
-			b.archived,
-			COALESCE(
-				(
-					SELECT json_agg(json_build_object(
-						'id', m.id,
-						'type', m.type,
-						'url', m.url,
-						'thumbnail', m.thumbnail,
-						'original', m.original
-					))
-					FROM media m
-					WHERE m.tweet_id = b.id
-				),
-				'[]'::json
-			) as media_json,
-			COALESCE(
-				(
-					SELECT json_agg(json_build_object(
-						'id', t.id,
-						'name', t.name,
-						'completed', bt.completed
-					))
-					FROM tags t
-					JOIN bookmark_tags bt ON bt.tag_id = t.id
-					WHERE bt.bookmark_id = b.id
-				),
-				'[]'::json
-			) as tags_json
-		FROM bookmarks b
-		GROUP BY b.id
-		ORDER BY b.created_at DESC;
-
-		CREATE UNIQUE INDEX idx_bookmark_views_id ON bookmark_views(id);
-		CREATE INDEX idx_bookmark_views_archived_created ON bookmark_views(archived, created_at DESC);
-	`).Error
-}
@@ -0,0 +1,224 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationLockID is the advisory lock key that serializes migrations run
+// by several processes at once
+const migrationLockID = 7_402_715
+
+// ErrSchemaTooNew is returned when the database has migrations this build
+// doesn't know, applied by a newer version
+var ErrSchemaTooNew = errors.New("database schema is newer than this build")
+
+// ErrPendingMigrations is returned at startup when migrations are pending
+// and automatic migration is turned off
+var ErrPendingMigrations = errors.New("database schema has pending migrations")
+
+// Migration is a numbered schema change. Up and Down each run in a
+// transaction together with the schema_migrations bookkeeping.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// MigrationStatus is a known or applied migration; AppliedAt is nil for
+// pending ones and Name is empty for ones this build doesn't know
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// schemaMigration is a row of the schema version table
+type schemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"type:varchar(100);not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// sqlMigration returns a migration step that runs one or more statements
+func sqlMigration(statements string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Exec(statements).Error
+	}
+}
+
+// LatestVersion is the version of the newest migration in this build
+func LatestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+func ensureMigrationTable(db *gorm.DB) error {
+	return db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version integer PRIMARY KEY,
+			name varchar(100) NOT NULL,
+			applied_at timestamptz NOT NULL
+		)
+	`).Error
+}
+
+func appliedMigrations(db *gorm.DB) ([]schemaMigration, error) {
+	var applied []schemaMigration
+	if err := db.Order("version").Find(&applied).Error; err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// CheckSchema returns ErrSchemaTooNew when the database has been migrated
+// past this build, and otherwise the number of pending migrations
+func CheckSchema(db *gorm.DB) (int, error) {
+	if err := ensureMigrationTable(db); err != nil {
+		return 0, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+
+	done := make(map[int]bool, len(applied))
+	for _, m := range applied {
+		if findMigration(m.Version) == nil {
+			return 0, fmt.Errorf("%w: migration %d (%s) is applied, but this build only knows up to %d",
+				ErrSchemaTooNew, m.Version, m.Name, LatestVersion())
+		}
+		done[m.Version] = true
+	}
+
+	pending := 0
+	for _, m := range migrations {
+		if !done[m.Version] {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// Status lists every known migration and any unknown applied ones by version
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	if err := ensureMigrationTable(db); err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*MigrationStatus)
+	for _, m := range migrations {
+		byVersion[m.Version] = &MigrationStatus{Version: m.Version, Name: m.Name}
+	}
+	for _, m := range applied {
+		appliedAt := m.AppliedAt
+		status, ok := byVersion[m.Version]
+		if !ok {
+			status = &MigrationStatus{Version: m.Version}
+			byVersion[m.Version] = status
+		}
+		status.AppliedAt = &appliedAt
+	}
+
+	statuses := make([]MigrationStatus, 0, len(byVersion))
+	for _, status := range byVersion {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// MigrateUp applies the pending migrations in order and returns them
+func MigrateUp(db *gorm.DB) ([]Migration, error) {
+	if _, err := CheckSchema(db); err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, m := range migrations {
+		applied, err := runMigration(db, m, true)
+		if err != nil {
+			return ran, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		if applied {
+			ran = append(ran, m)
+		}
+	}
+	return ran, nil
+}
+
+// MigrateDown reverts the latest steps applied migrations and returns them
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	if _, err := CheckSchema(db); err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for i := len(applied) - 1; i >= 0 && len(ran) < steps; i-- {
+		m := *findMigration(applied[i].Version)
+		if _, err := runMigration(db, m, false); err != nil {
+			return ran, fmt.Errorf("reverting migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		ran = append(ran, m)
+	}
+	return ran, nil
+}
+
+// runMigration applies or reverts a migration under the advisory lock,
+// reporting false when another process already did
+func runMigration(db *gorm.DB, m Migration, up bool) (bool, error) {
+	ran := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&schemaMigration{}).Where("version = ?", m.Version).Count(&count).Error; err != nil {
+			return err
+		}
+		if (count > 0) == up {
+			return nil
+		}
+
+		if up {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			ran = true
+			return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		}
+
+		if err := m.Down(tx); err != nil {
+			return err
+		}
+		ran = true
+		return tx.Delete(&schemaMigration{}, "version = ?", m.Version).Error
+	})
+	return ran, err
+}
+
+func findMigration(version int) *Migration {
+	for i := range migrations {
+		if migrations[i].Version == version {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+)
+
+// normalizedTagName is the tag name normalization as of migration 11. It is
+// a copy rather than models.NormalizeTagName so the migration stays fixed.
+// Colons, which tag filters reserve for ":completed" and ":pending", become
+// spaces.
+func normalizedTagName(name string) string {
+	name = strings.ReplaceAll(name, ":", " ")
+	return norm.NFC.String(strings.Join(strings.Fields(name), " "))
+}
+
+// migrationTag is a row of tags as the name migration sees it
+type migrationTag struct {
+	ID       uint
+	Name     string
+	ParentID *uint
+}
+
+// normalizeTagNamesUp renames every tag to its normalized form and merges
+// tags whose names collide ignoring case into the oldest of them, then makes
+// tag and alias names unique ignoring case
+func normalizeTagNamesUp(tx *gorm.DB) error {
+	// Drop the old constraints first so renames can't clash with duplicates
+	// that haven't been merged yet
+	if err := tx.Exec(`
+		ALTER TABLE tags DROP CONSTRAINT IF EXISTS uni_tags_name;
+		ALTER TABLE tags DROP CONSTRAINT IF EXISTS tags_name_key;
+		DROP INDEX IF EXISTS idx_tag_aliases_name;
+	`).Error; err != nil {
+		return err
+	}
+
+	var tags []migrationTag
+	if err := tx.Raw(`SELECT id, name, parent_id FROM tags ORDER BY id FOR UPDATE`).Scan(&tags).Error; err != nil {
+		return err
+	}
+
+	parents := make(map[uint]*uint, len(tags))
+	canonical := make(map[string]uint, len(tags))
+	for _, tag := range tags {
+		parents[tag.ID] = tag.ParentID
+	}
+
+	for _, tag := range tags {
+		name := normalizedTagName(tag.Name)
+		if name == "" {
+			name = fmt.Sprintf("Tag %d", tag.ID)
+		}
+		key := strings.ToLower(name)
+		targetID, seen := canonical[key]
+		if !seen {
+			canonical[key] = tag.ID
+			if name != tag.Name {
+				if err := tx.Exec(`UPDATE tags SET name = ? WHERE id = ?`, name, tag.ID).Error; err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := mergeDuplicateTag(tx, tag.ID, targetID, parents); err != nil {
+			return err
+		}
+	}
+
+	var aliases []struct {
+		ID   uint
+		Name string
+	}
+	if err := tx.Raw(`SELECT id, name FROM tag_aliases ORDER BY id`).Scan(&aliases).Error; err != nil {
+		return err
+	}
+	for _, alias := range aliases {
+		name := normalizedTagName(alias.Name)
+		key := strings.ToLower(name)
+		if _, taken := canonical[key]; taken || name == "" {
+			if err := tx.Exec(`DELETE FROM tag_aliases WHERE id = ?`, alias.ID).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		canonical[key] = 0
+		if name != alias.Name {
+			if err := tx.Exec(`UPDATE tag_aliases SET name = ? WHERE id = ?`, name, alias.ID).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Exec(`
+		CREATE UNIQUE INDEX idx_tags_name_lower ON tags (LOWER(name));
+		CREATE UNIQUE INDEX idx_tag_aliases_name_lower ON tag_aliases (LOWER(name));
+		REFRESH MATERIALIZED VIEW bookmark_views;
+	`).Error
+}
+
+// mergeDuplicateTag moves the bookmarks, children and aliases of a tag onto
+// the tag it collides with and deletes it. A bookmark tagged with both keeps
+// one link that is completed if either was. Children are moved to the
+// duplicate's parent instead when the target is one of them, so no cycle
+// forms.
+func mergeDuplicateTag(tx *gorm.DB, sourceID, targetID uint, parents map[uint]*uint) error {
+	if err := tx.Exec(`
+		UPDATE bookmark_tags AS target SET completed = target.completed OR source.completed
+		FROM bookmark_tags AS source
+		WHERE target.tag_id = ? AND source.tag_id = ? AND target.bookmark_id = source.bookmark_id
+	`, targetID, sourceID).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec(`
+		INSERT INTO bookmark_tags (bookmark_id, tag_id, created_at, completed)
+		SELECT bookmark_id, ?, created_at, completed FROM bookmark_tags WHERE tag_id = ?
+		ON CONFLICT DO NOTHING
+	`, targetID, sourceID).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec(`DELETE FROM bookmark_tags WHERE tag_id = ?`, sourceID).Error; err != nil {
+		return err
+	}
+
+	newParent := &targetID
+	for id := parents[targetID]; id != nil; id = parents[*id] {
+		if *id == sourceID {
+			newParent = parents[sourceID]
+			break
+		}
+	}
+	for id, parent := range parents {
+		if parent != nil && *parent == sourceID {
+			parents[id] = newParent
+		}
+	}
+	if err := tx.Exec(`UPDATE tags SET parent_id = ? WHERE parent_id = ?`, newParent, sourceID).Error; err != nil {
+		return err
+	}
+	delete(parents, sourceID)
+
+	if err := tx.Exec(`UPDATE tag_aliases SET tag_id = ? WHERE tag_id = ?`, targetID, sourceID).Error; err != nil {
+		return err
+	}
+	return tx.Exec(`DELETE FROM tags WHERE id = ?`, sourceID).Error
+}
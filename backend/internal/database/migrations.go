@@ -0,0 +1,431 @@
+package database
+
+// migrations are the schema changes in version order. Applied migrations
+// must never change: alter the schema by appending a new one, and keep the
+// GORM tags of the models in step with it.
+//
+// The early migrations use IF NOT EXISTS so they also adopt databases that
+// were created by AutoMigrate before migrations were versioned.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial_schema",
+		Up: sqlMigration(`
+			CREATE TABLE IF NOT EXISTS bookmarks (
+				id varchar(30) PRIMARY KEY,
+				created_at timestamptz,
+				full_text text,
+				screen_name varchar(50),
+				name varchar(100),
+				profile_image_url text,
+				in_reply_to varchar(30),
+				retweeted_status varchar(30),
+				quoted_status varchar(30),
+				favorite_count bigint,
+				retweet_count bigint,
+				bookmark_count bigint,
+				quote_count bigint,
+				reply_count bigint,
+				views_count bigint,
+				favorited boolean,
+				retweeted boolean,
+				bookmarked boolean,
+				url text,
+				metadata jsonb,
+				updated_at timestamptz,
+				archived boolean DEFAULT false
+			);
+			CREATE INDEX IF NOT EXISTS "idx_archived_createdAt" ON bookmarks (created_at, archived);
+
+			CREATE TABLE IF NOT EXISTS media (
+				id bigserial PRIMARY KEY,
+				tweet_id varchar(30),
+				type varchar(20),
+				url text,
+				thumbnail text,
+				original text,
+				file_data bytea,
+				file_name varchar(255),
+				created_at timestamptz,
+				updated_at timestamptz,
+				CONSTRAINT fk_bookmarks_media FOREIGN KEY (tweet_id) REFERENCES bookmarks (id)
+			);
+			CREATE INDEX IF NOT EXISTS idx_media_tweet ON media (tweet_id);
+
+			CREATE TABLE IF NOT EXISTS tags (
+				id bigserial PRIMARY KEY,
+				name varchar(50) CONSTRAINT uni_tags_name UNIQUE,
+				created_at timestamptz,
+				updated_at timestamptz,
+				completed boolean
+			);
+
+			CREATE TABLE IF NOT EXISTS bookmark_tags (
+				bookmark_id varchar(30),
+				tag_id bigint,
+				created_at timestamptz,
+				completed boolean DEFAULT false,
+				PRIMARY KEY (bookmark_id, tag_id),
+				CONSTRAINT fk_bookmark_tags_bookmark FOREIGN KEY (bookmark_id) REFERENCES bookmarks (id),
+				CONSTRAINT fk_bookmark_tags_tag FOREIGN KEY (tag_id) REFERENCES tags (id)
+			);
+			CREATE INDEX IF NOT EXISTS idx_bookmark_id ON bookmark_tags (bookmark_id);
+			CREATE INDEX IF NOT EXISTS idx_tag_id ON bookmark_tags (tag_id);
+			CREATE INDEX IF NOT EXISTS idx_completed ON bookmark_tags (completed);
+		`),
+		Down: sqlMigration(`DROP TABLE IF EXISTS bookmark_tags, tags, media, bookmarks`),
+	},
+	{
+		Version: 2,
+		Name:    "bookmark_imported_at",
+		// Bookmarks imported before imported_at existed fall back to their last update
+		Up: sqlMigration(`
+			ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS imported_at timestamptz;
+			UPDATE bookmarks SET imported_at = updated_at WHERE imported_at IS NULL;
+		`),
+		Down: sqlMigration(`ALTER TABLE bookmarks DROP COLUMN IF EXISTS imported_at`),
+	},
+	{
+		Version: 3,
+		Name:    "import_jobs",
+		// Jobs created before CSV uploads named their data file after JSON
+		Up: sqlMigration(`
+			DO $$
+			BEGIN
+				IF EXISTS (
+					SELECT 1 FROM information_schema.columns
+					WHERE table_schema = current_schema() AND table_name = 'import_jobs' AND column_name = 'json_file_name'
+				) THEN
+					ALTER TABLE import_jobs RENAME COLUMN json_file_name TO data_file_name;
+				END IF;
+			END $$;
+
+			CREATE TABLE IF NOT EXISTS import_jobs (
+				id bigserial PRIMARY KEY,
+				phase varchar(20) NOT NULL,
+				format varchar(30),
+				data_file_name varchar(255),
+				zip_file_name varchar(255),
+				storage_path text,
+				total bigint,
+				processed bigint,
+				failed_count bigint,
+				error text,
+				cancel_requested boolean DEFAULT false,
+				dry_run boolean DEFAULT false,
+				merge_policy varchar(20) DEFAULT 'overwrite',
+				importer_options jsonb,
+				report jsonb,
+				started_at timestamptz,
+				finished_at timestamptz,
+				created_at timestamptz,
+				updated_at timestamptz
+			);
+			CREATE INDEX IF NOT EXISTS idx_import_jobs_phase ON import_jobs (phase);
+
+			CREATE TABLE IF NOT EXISTS import_failures (
+				id bigserial PRIMARY KEY,
+				import_job_id bigint NOT NULL,
+				bookmark_id varchar(30),
+				error text,
+				created_at timestamptz,
+				CONSTRAINT fk_import_jobs_failures FOREIGN KEY (import_job_id) REFERENCES import_jobs (id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_import_failures_import_job_id ON import_failures (import_job_id);
+		`),
+		Down: sqlMigration(`DROP TABLE IF EXISTS import_failures, import_jobs`),
+	},
+	{
+		Version: 4,
+		Name:    "bookmark_search_vector",
+		// Tweet text ranks above author names, which rank above the titles and
+		// domains of links extracted from the tweet metadata
+		Up: sqlMigration(`
+			ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector('english', COALESCE(full_text, '')), 'A') ||
+				setweight(to_tsvector('english', COALESCE(name, '') || ' ' || COALESCE(screen_name, '')), 'B') ||
+				setweight(to_tsvector('english', COALESCE(
+					jsonb_path_query_array(metadata, '$.**.binding_values ? (@.key == "title").value.string_value')::text || ' ' ||
+					jsonb_path_query_array(metadata, '$.**.binding_values.title.string_value')::text || ' ' ||
+					jsonb_path_query_array(metadata, '$.**.urls.display_url')::text,
+					''
+				)), 'C')
+			) STORED;
+			CREATE INDEX IF NOT EXISTS idx_bookmarks_search_vector ON bookmarks USING GIN (search_vector);
+		`),
+		Down: sqlMigration(`
+			DROP INDEX IF EXISTS idx_bookmarks_search_vector;
+			ALTER TABLE bookmarks DROP COLUMN IF EXISTS search_vector;
+		`),
+	},
+	{
+		Version: 5,
+		Name:    "trigram_search",
+		Up: sqlMigration(`
+			CREATE EXTENSION IF NOT EXISTS pg_trgm;
+			CREATE INDEX IF NOT EXISTS idx_tags_name_trgm ON tags USING GIN (name gin_trgm_ops);
+		`),
+		Down: sqlMigration(`DROP INDEX IF EXISTS idx_tags_name_trgm`),
+	},
+	{
+		Version: 6,
+		Name:    "saved_searches",
+		Up: sqlMigration(`
+			CREATE TABLE IF NOT EXISTS saved_searches (
+				id bigserial PRIMARY KEY,
+				name varchar(100) NOT NULL,
+				search text,
+				fuzzy boolean DEFAULT false,
+				tags jsonb,
+				tag_mode varchar(10),
+				exclude_tags jsonb,
+				untagged boolean DEFAULT false,
+				archived boolean DEFAULT false,
+				sort varchar(20),
+				"order" varchar(4),
+				created_at timestamptz,
+				updated_at timestamptz
+			);
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_saved_searches_name ON saved_searches (name);
+		`),
+		Down: sqlMigration(`DROP TABLE IF EXISTS saved_searches`),
+	},
+	{
+		Version: 7,
+		Name:    "lists",
+		Up: sqlMigration(`
+			CREATE TABLE lists (
+				id bigserial PRIMARY KEY,
+				name varchar(100) NOT NULL,
+				created_at timestamptz,
+				updated_at timestamptz
+			);
+
+			CREATE TABLE list_bookmarks (
+				list_id bigint,
+				bookmark_id varchar(30),
+				position bigint NOT NULL,
+				created_at timestamptz,
+				updated_at timestamptz,
+				PRIMARY KEY (list_id, bookmark_id),
+				CONSTRAINT fk_list_bookmarks_list FOREIGN KEY (list_id) REFERENCES lists (id) ON DELETE CASCADE,
+				CONSTRAINT fk_list_bookmarks_bookmark FOREIGN KEY (bookmark_id) REFERENCES bookmarks (id) ON DELETE CASCADE
+			);
+			CREATE INDEX idx_list_bookmarks_position ON list_bookmarks (list_id, position);
+		`),
+		Down: sqlMigration(`DROP TABLE IF EXISTS list_bookmarks, lists`),
+	},
+	{
+		Version: 8,
+		Name:    "bookmark_views",
+		// Replaces the view earlier versions recreated on every startup
+		Up:   sqlMigration(`DROP MATERIALIZED VIEW IF EXISTS bookmark_views;` + bookmarkViewV1),
+		Down: sqlMigration(`DROP MATERIALIZED VIEW IF EXISTS bookmark_views`),
+	},
+	{
+		Version: 9,
+		Name:    "tag_parents",
+		// Deleting a tag makes its children root tags unless they are moved first
+		Up: sqlMigration(`
+			ALTER TABLE tags ADD COLUMN parent_id bigint;
+			ALTER TABLE tags ADD CONSTRAINT fk_tags_parent FOREIGN KEY (parent_id) REFERENCES tags (id) ON DELETE SET NULL;
+			CREATE INDEX idx_tags_parent_id ON tags (parent_id);
+		`),
+		Down: sqlMigration(`ALTER TABLE tags DROP COLUMN IF EXISTS parent_id`),
+	},
+	{
+		Version: 10,
+		Name:    "tag_aliases",
+		Up: sqlMigration(`
+			CREATE TABLE tag_aliases (
+				id bigserial PRIMARY KEY,
+				name varchar(50) NOT NULL,
+				tag_id bigint NOT NULL,
+				created_at timestamptz,
+				CONSTRAINT fk_tags_aliases FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+			);
+			CREATE UNIQUE INDEX idx_tag_aliases_name ON tag_aliases (name);
+			CREATE INDEX idx_tag_aliases_tag_id ON tag_aliases (tag_id);
+		`),
+		Down: sqlMigration(`DROP TABLE IF EXISTS tag_aliases`),
+	},
+	{
+		Version: 11,
+		Name:    "normalize_tag_names",
+		// Merged duplicates stay merged when migrating down
+		Up: normalizeTagNamesUp,
+		Down: sqlMigration(`
+			DROP INDEX IF EXISTS idx_tags_name_lower;
+			ALTER TABLE tags ADD CONSTRAINT uni_tags_name UNIQUE (name);
+			DROP INDEX IF EXISTS idx_tag_aliases_name_lower;
+			CREATE UNIQUE INDEX idx_tag_aliases_name ON tag_aliases (name);
+		`),
+	},
+	{
+		Version: 12,
+		Name:    "tag_metadata",
+		Up: sqlMigration(`
+			ALTER TABLE tags
+				ADD COLUMN color varchar(7) NOT NULL DEFAULT '',
+				ADD COLUMN icon varchar(32) NOT NULL DEFAULT '',
+				ADD COLUMN description text NOT NULL DEFAULT '',
+				ADD COLUMN position bigint;
+			DROP MATERIALIZED VIEW IF EXISTS bookmark_views;
+		` + bookmarkViewV2),
+		Down: sqlMigration(`
+			DROP MATERIALIZED VIEW IF EXISTS bookmark_views;
+		` + bookmarkViewV1 + `
+			ALTER TABLE tags
+				DROP COLUMN IF EXISTS color,
+				DROP COLUMN IF EXISTS icon,
+				DROP COLUMN IF EXISTS description,
+				DROP COLUMN IF EXISTS position;
+		`),
+	},
+}
+
+// bookmarkViewV1 creates the materialized view the bookmark list reads from,
+// with indexes for each sort order, full-text and trigram search
+const bookmarkViewV1 = `
+	CREATE MATERIALIZED VIEW bookmark_views AS
+	SELECT
+		b.id,
+		b.created_at,
+		b.full_text,
+		b.screen_name,
+		b.name,
+		b.profile_image_url,
+		b.favorite_count,
+		b.retweet_count,
+		b.views_count,
+		b.url,
+		b.archived,
+		b.imported_at,
+		(SELECT MAX(bt.created_at) FROM bookmark_tags bt WHERE bt.bookmark_id = b.id) as tagged_at,
+		b.in_reply_to,
+		b.quoted_status,
+		b.search_vector,
+		ARRAY(
+			SELECT DISTINCT LOWER(h[1])
+			FROM regexp_matches(b.full_text, '#(\w+)', 'g') AS h
+		) as hashtags,
+		COALESCE(
+			(
+				SELECT json_agg(json_build_object(
+					'id', m.id,
+					'type', m.type,
+					'url', m.url,
+					'thumbnail', m.thumbnail,
+					'original', m.original
+				))
+				FROM media m
+				WHERE m.tweet_id = b.id
+			),
+			'[]'::json
+		) as media_json,
+		COALESCE(
+			(
+				SELECT json_agg(json_build_object(
+					'id', t.id,
+					'name', t.name,
+					'completed', bt.completed
+				))
+				FROM tags t
+				JOIN bookmark_tags bt ON bt.tag_id = t.id
+				WHERE bt.bookmark_id = b.id
+			),
+			'[]'::json
+		) as tags_json
+	FROM bookmarks b
+	GROUP BY b.id
+	ORDER BY b.created_at DESC;
+
+	CREATE UNIQUE INDEX idx_bookmark_views_id ON bookmark_views(id);
+	CREATE INDEX idx_bookmark_views_archived_created ON bookmark_views(archived, created_at DESC, id DESC);
+	CREATE INDEX idx_bookmark_views_search_vector ON bookmark_views USING GIN (search_vector);
+	CREATE INDEX idx_bookmark_views_archived_imported ON bookmark_views(archived, imported_at DESC, id DESC);
+	CREATE INDEX idx_bookmark_views_archived_favorites ON bookmark_views(archived, favorite_count DESC, id DESC);
+	CREATE INDEX idx_bookmark_views_archived_retweets ON bookmark_views(archived, retweet_count DESC, id DESC);
+	CREATE INDEX idx_bookmark_views_archived_views ON bookmark_views(archived, views_count DESC, id DESC);
+	CREATE INDEX idx_bookmark_views_archived_author ON bookmark_views(archived, LOWER(screen_name), id);
+	CREATE INDEX idx_bookmark_views_archived_tagged ON bookmark_views(archived, tagged_at DESC NULLS LAST, id DESC);
+	CREATE INDEX idx_bookmark_views_screen_name_trgm ON bookmark_views USING GIN (screen_name gin_trgm_ops);
+	CREATE INDEX idx_bookmark_views_name_trgm ON bookmark_views USING GIN (name gin_trgm_ops);
+	CREATE INDEX idx_bookmark_views_full_text_trgm ON bookmark_views USING GIN (full_text gin_trgm_ops);
+	CREATE INDEX idx_bookmark_views_hashtags ON bookmark_views USING GIN (hashtags);
+`
+
+// bookmarkViewV2 adds the tag metadata to tags_json, in sidebar order
+const bookmarkViewV2 = `
+	CREATE MATERIALIZED VIEW bookmark_views AS
+	SELECT
+		b.id,
+		b.created_at,
+		b.full_text,
+		b.screen_name,
+		b.name,
+		b.profile_image_url,
+		b.favorite_count,
+		b.retweet_count,
+		b.views_count,
+		b.url,
+		b.archived,
+		b.imported_at,
+		(SELECT MAX(bt.created_at) FROM bookmark_tags bt WHERE bt.bookmark_id = b.id) as tagged_at,
+		b.in_reply_to,
+		b.quoted_status,
+		b.search_vector,
+		ARRAY(
+			SELECT DISTINCT LOWER(h[1])
+			FROM regexp_matches(b.full_text, '#(\w+)', 'g') AS h
+		) as hashtags,
+		COALESCE(
+			(
+				SELECT json_agg(json_build_object(
+					'id', m.id,
+					'type', m.type,
+					'url', m.url,
+					'thumbnail', m.thumbnail,
+					'original', m.original
+				))
+				FROM media m
+				WHERE m.tweet_id = b.id
+			),
+			'[]'::json
+		) as media_json,
+		COALESCE(
+			(
+				SELECT json_agg(json_build_object(
+					'id', t.id,
+					'name', t.name,
+					'completed', bt.completed,
+					'color', t.color,
+					'icon', t.icon,
+					'description', t.description,
+					'position', t.position
+				) ORDER BY t.position NULLS LAST, LOWER(t.name))
+				FROM tags t
+				JOIN bookmark_tags bt ON bt.tag_id = t.id
+				WHERE bt.bookmark_id = b.id
+			),
+			'[]'::json
+		) as tags_json
+	FROM bookmarks b
+	GROUP BY b.id
+	ORDER BY b.created_at DESC;
+
+	CREATE UNIQUE INDEX idx_bookmark_views_id ON bookmark_views(id);
+	CREATE INDEX idx_bookmark_views_archived_created ON bookmark_views(archived, created_at DESC, id DESC);
+	CREATE INDEX idx_bookmark_views_search_vector ON bookmark_views USING GIN (search_vector);
+	CREATE INDEX idx_bookmark_views_archived_imported ON bookmark_views(archived, imported_at DESC, id DESC);
+	CREATE INDEX idx_bookmark_views_archived_favorites ON bookmark_views(archived, favorite_count DESC, id DESC);
+	CREATE INDEX idx_bookmark_views_archived_retweets ON bookmark_views(archived, retweet_count DESC, id DESC);
+	CREATE INDEX idx_bookmark_views_archived_views ON bookmark_views(archived, views_count DESC, id DESC);
+	CREATE INDEX idx_bookmark_views_archived_author ON bookmark_views(archived, LOWER(screen_name), id);
+	CREATE INDEX idx_bookmark_views_archived_tagged ON bookmark_views(archived, tagged_at DESC NULLS LAST, id DESC);
+	CREATE INDEX idx_bookmark_views_screen_name_trgm ON bookmark_views USING GIN (screen_name gin_trgm_ops);
+	CREATE INDEX idx_bookmark_views_name_trgm ON bookmark_views USING GIN (name gin_trgm_ops);
+	CREATE INDEX idx_bookmark_views_full_text_trgm ON bookmark_views USING GIN (full_text gin_trgm_ops);
+	CREATE INDEX idx_bookmark_views_hashtags ON bookmark_views USING GIN (hashtags);
+`
@@ -0,0 +1,88 @@
+package importers
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+)
+
+// Archive is an uploaded ZIP opened once per import, with its entries
+// indexed by name so each media file is a map lookup away
+type Archive struct {
+	reader  *zip.ReadCloser
+	entries map[string]*zip.File
+}
+
+func OpenArchive(zipPath string) (*Archive, error) {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip archive: %w", err)
+	}
+
+	entries := make(map[string]*zip.File, len(reader.File))
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		entries[file.Name] = file
+	}
+
+	// Exporters sometimes wrap the media in a top-level folder, so also index
+	// entries by base name without shadowing exact matches
+	for _, file := range reader.File {
+		base := path.Base(file.Name)
+		if _, exists := entries[base]; !exists && !file.FileInfo().IsDir() {
+			entries[base] = file
+		}
+	}
+
+	return &Archive{reader: reader, entries: entries}, nil
+}
+
+// Files lists every entry in the archive in central directory order
+func (a *Archive) Files() []*zip.File {
+	return a.reader.File
+}
+
+// Open streams a single entry without decompressing it up front
+func (a *Archive) Open(name string) (io.ReadCloser, error) {
+	file, ok := a.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in zip", name)
+	}
+	return file.Open()
+}
+
+// Has reports whether the archive contains the named file
+func (a *Archive) Has(name string) bool {
+	_, ok := a.entries[name]
+	return ok
+}
+
+// ReadFile decompresses a single entry. It returns nil without an error when
+// the entry doesn't exist so processing can continue.
+func (a *Archive) ReadFile(name string) ([]byte, error) {
+	file, ok := a.entries[name]
+	if !ok {
+		return nil, nil
+	}
+
+	rc, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file in zip: %w", err)
+	}
+	defer rc.Close()
+
+	var buf bytes.Buffer
+	buf.Grow(int(file.UncompressedSize64))
+	if _, err := io.Copy(&buf, rc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (a *Archive) Close() error {
+	return a.reader.Close()
+}
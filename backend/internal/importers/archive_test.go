@@ -0,0 +1,106 @@
+package importers
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// BenchmarkArchiveReadAll reads every media file of a synthetic export by
+// name, as an import does. "indexed" is Archive, which opens the ZIP once
+// and looks names up in an index, so its cost grows linearly with the number
+// of files. "reopen" is the approach it replaced, reopening the ZIP and
+// scanning its entries for each file, which grows quadratically.
+func BenchmarkArchiveReadAll(b *testing.B) {
+	for _, count := range []int{1000, 4000} {
+		data, names := syntheticArchive(b, count)
+		path := filepath.Join(b.TempDir(), "media.zip")
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("indexed/files=%d", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				archive, err := OpenArchive(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for _, name := range names {
+					content, err := archive.ReadFile(name)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if content == nil {
+						b.Fatalf("%s not found", name)
+					}
+				}
+				archive.Close()
+			}
+		})
+
+		b.Run(fmt.Sprintf("reopen/files=%d", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, name := range names {
+					content, err := reopenAndScan(path, "bookmarks_media/"+name)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if content == nil {
+						b.Fatalf("%s not found", name)
+					}
+				}
+			}
+		})
+	}
+}
+
+// reopenAndScan is the per-file lookup imports used before Archive
+func reopenAndScan(path, name string) ([]byte, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if file.Name == name {
+			rc, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return io.ReadAll(rc)
+		}
+	}
+	return nil, nil
+}
+
+// syntheticArchive builds an in-memory ZIP shaped like an export, with the
+// media wrapped in a top-level folder, and returns the names an import looks
+// up
+func syntheticArchive(b *testing.B, count int) ([]byte, []string) {
+	b.Helper()
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	names := make([]string, count)
+	content := bytes.Repeat([]byte("media"), 200)
+	for i := range names {
+		names[i] = fmt.Sprintf("%d-photo.jpg", 1_700_000_000_000_000_000+i)
+		entry, err := writer.Create("bookmarks_media/" + names[i])
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := entry.Write(content); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Bytes(), names
+}
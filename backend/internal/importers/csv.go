@@ -0,0 +1,419 @@
+package importers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helioLJ/tweetvault/internal/models"
+)
+
+// CSVMappingOption is the upload option holding a JSON object that maps CSV
+// headers onto bookmark fields, e.g. {"Tweet": "full_text", "Notes": ""}.
+// An empty field ignores the column.
+const CSVMappingOption = "csv_mapping"
+
+// csvHeaderAliases maps lower-cased header names onto the bookmark field
+// they fill. Field names are the JSON names of models.Bookmark, plus media
+// and tags. The canonical names match the columns written by the CSV export.
+var csvHeaderAliases = map[string]string{
+	"id":                "id",
+	"tweet_id":          "id",
+	"created_at":        "created_at",
+	"date":              "created_at",
+	"full_text":         "full_text",
+	"text":              "full_text",
+	"screen_name":       "screen_name",
+	"username":          "screen_name",
+	"handle":            "screen_name",
+	"name":              "name",
+	"display_name":      "name",
+	"profile_image_url": "profile_image_url",
+	"in_reply_to":       "in_reply_to",
+	"retweeted_status":  "retweeted_status",
+	"quoted_status":     "quoted_status",
+	"favorite_count":    "favorite_count",
+	"likes":             "favorite_count",
+	"retweet_count":     "retweet_count",
+	"retweets":          "retweet_count",
+	"bookmark_count":    "bookmark_count",
+	"quote_count":       "quote_count",
+	"reply_count":       "reply_count",
+	"replies":           "reply_count",
+	"views_count":       "views_count",
+	"views":             "views_count",
+	"favorited":         "favorited",
+	"retweeted":         "retweeted",
+	"bookmarked":        "bookmarked",
+	"url":               "url",
+	"link":              "url",
+	"archived":          "archived",
+	"media":             "media",
+	"tags":              "tags",
+}
+
+// csvImporter reads CSV exports, such as the one twitter-web-exporter writes
+// or a hand-curated spreadsheet, with an optional header mapping
+type csvImporter struct{}
+
+func (csvImporter) Name() string {
+	return "csv"
+}
+
+func (csvImporter) Detect(upload *Upload) bool {
+	if upload.DataPath == "" {
+		return false
+	}
+	if strings.HasSuffix(strings.ToLower(upload.DataFileName), ".csv") {
+		return true
+	}
+
+	// Without the extension, accept files whose header names an ID column
+	firstLine, _, _ := strings.Cut(string(PeekData(upload, 4096)), "\n")
+	reader := csv.NewReader(strings.NewReader(firstLine))
+	reader.Comma = sniffDelimiter(firstLine)
+	header, err := reader.Read()
+	if err != nil {
+		return false
+	}
+	columns, err := mapCSVColumns(header, nil)
+	return err == nil && columns["id"] >= 0
+}
+
+func (csvImporter) Open(upload *Upload) (Source, int, error) {
+	var mapping map[string]string
+	if raw := upload.Options[CSVMappingOption]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			return nil, 0, fmt.Errorf("invalid %s: %w", CSVMappingOption, err)
+		}
+	}
+
+	source, err := openCSVSource(upload.DataPath, mapping)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := countCSVRecords(upload.DataPath, source.reader.Comma)
+	if err != nil {
+		source.Close()
+		return nil, 0, err
+	}
+	return source, total, nil
+}
+
+// ValidateCSVMapping checks a csv_mapping option before an import is queued
+func ValidateCSVMapping(raw string) error {
+	var mapping map[string]string
+	if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+		return fmt.Errorf("invalid %s: %w", CSVMappingOption, err)
+	}
+	for header, field := range mapping {
+		if field != "" && !isCSVField(field) {
+			return fmt.Errorf("invalid %s: column %q maps to unknown field %q", CSVMappingOption, header, field)
+		}
+	}
+	return nil
+}
+
+type csvSource struct {
+	file    *os.File
+	reader  *csv.Reader
+	columns map[string]int // Bookmark field to column index
+	line    int
+}
+
+func openCSVSource(path string, mapping map[string]string) (*csvSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := newCSVReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns, err := mapCSVColumns(header, mapping)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if columns["id"] < 0 {
+		file.Close()
+		return nil, errors.New("CSV has no id column; map one with " + CSVMappingOption)
+	}
+
+	return &csvSource{file: file, reader: reader, columns: columns, line: 1}, nil
+}
+
+func (s *csvSource) Next(item *Item) (bool, error) {
+	row, err := s.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return false, nil
+	}
+	s.line++
+	if err != nil {
+		return false, err
+	}
+
+	*item = s.mapRow(row)
+	return true, nil
+}
+
+func (s *csvSource) Close() error {
+	return s.file.Close()
+}
+
+// mapRow converts a record into an item. Bad cells don't stop the import:
+// the item carries the error and is recorded as a failure.
+func (s *csvSource) mapRow(row []string) Item {
+	var item Item
+	var problems []string
+
+	get := func(field string) string {
+		if i := s.columns[field]; i >= 0 && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	getInt := func(field string) int {
+		value := strings.ReplaceAll(get(field), ",", "")
+		if value == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %q is not a number", field, get(field)))
+		}
+		return n
+	}
+	getBool := func(field string) bool {
+		value := get(field)
+		if value == "" {
+			return false
+		}
+		b, err := strconv.ParseBool(strings.ToLower(value))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %q is not a boolean", field, value))
+		}
+		return b
+	}
+	id := get("id")
+	if id == "" {
+		item.Err = fmt.Errorf("line %d: missing id", s.line)
+		return item
+	}
+
+	createdAt, err := parseLooseTime(get("created_at"))
+	if err != nil {
+		createdAt = snowflakeTime(id)
+		if get("created_at") != "" {
+			item.InvalidDate = &models.InvalidDate{
+				BookmarkID: id,
+				Value:      get("created_at"),
+				UsedValue:  createdAt,
+			}
+		}
+	}
+
+	item.Bookmark = models.Bookmark{
+		ID:              id,
+		CreatedAt:       createdAt,
+		FullText:        get("full_text"),
+		ScreenName:      strings.TrimPrefix(get("screen_name"), "@"),
+		Name:            get("name"),
+		ProfileImageURL: get("profile_image_url"),
+		FavoriteCount:   getInt("favorite_count"),
+		RetweetCount:    getInt("retweet_count"),
+		BookmarkCount:   getInt("bookmark_count"),
+		QuoteCount:      getInt("quote_count"),
+		ReplyCount:      getInt("reply_count"),
+		ViewsCount:      getInt("views_count"),
+		Favorited:       getBool("favorited"),
+		Retweeted:       getBool("retweeted"),
+		Bookmarked:      getBool("bookmarked"),
+		URL:             get("url"),
+		Archived:        getBool("archived"), // Only applies to new bookmarks
+	}
+	if item.Bookmark.URL == "" {
+		item.Bookmark.URL = statusURL(item.Bookmark.ScreenName, id)
+	}
+	if v := get("in_reply_to"); v != "" {
+		item.Bookmark.InReplyTo.String, item.Bookmark.InReplyTo.Valid = v, true
+	}
+	if v := get("retweeted_status"); v != "" {
+		item.Bookmark.RetweetedStatus.String, item.Bookmark.RetweetedStatus.Valid = v, true
+	}
+	if v := get("quoted_status"); v != "" {
+		item.Bookmark.QuotedStatus.String, item.Bookmark.QuotedStatus.Valid = v, true
+	}
+
+	if media := get("media"); strings.HasPrefix(media, "[") {
+		var entries []TwitterMedia
+		if err := json.Unmarshal([]byte(media), &entries); err != nil {
+			problems = append(problems, fmt.Sprintf("media: %v", err))
+		}
+		item.Media = mediaItems(item.Bookmark.ScreenName, id, entries)
+	}
+
+	tags, err := splitTags(get("tags"))
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("tags: %v", err))
+	}
+	item.Tags = tags
+
+	if len(problems) > 0 {
+		item.Err = fmt.Errorf("line %d: %s", s.line, strings.Join(problems, "; "))
+	}
+	return item
+}
+
+// mapCSVColumns resolves each bookmark field to a column index, -1 when
+// absent. Explicit mappings win over the built-in header aliases.
+func mapCSVColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	columns := make(map[string]int)
+	for _, field := range csvHeaderAliases {
+		columns[field] = -1
+	}
+
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		field, mapped := mapping[name]
+		if !mapped {
+			field = csvHeaderAliases[strings.ToLower(name)]
+		}
+		if field == "" {
+			continue
+		}
+		if !isCSVField(field) {
+			return nil, fmt.Errorf("column %q maps to unknown field %q", name, field)
+		}
+		if columns[field] < 0 {
+			columns[field] = i
+		}
+	}
+
+	return columns, nil
+}
+
+func isCSVField(field string) bool {
+	for _, known := range csvHeaderAliases {
+		if known == field {
+			return true
+		}
+	}
+	return false
+}
+
+func newCSVReader(file *os.File) (*csv.Reader, error) {
+	reader := bufio.NewReader(file)
+	if bom, _ := reader.Peek(3); string(bom) == "\xEF\xBB\xBF" {
+		reader.Discard(3)
+	}
+
+	firstLine, err := reader.Peek(4096)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, err
+	}
+	line, _, _ := strings.Cut(string(firstLine), "\n")
+
+	csvReader := csv.NewReader(reader)
+	csvReader.Comma = sniffDelimiter(line)
+	csvReader.FieldsPerRecord = -1 // Spreadsheets often drop trailing empty cells
+	csvReader.LazyQuotes = true
+	return csvReader, nil
+}
+
+// sniffDelimiter picks whichever of comma, semicolon or tab appears most in
+// the header line, since spreadsheet locales disagree on the separator
+func sniffDelimiter(line string) rune {
+	best, bestCount := ',', strings.Count(line, ",")
+	for _, candidate := range []rune{';', '\t'} {
+		if count := strings.Count(line, string(candidate)); count > bestCount {
+			best, bestCount = candidate, count
+		}
+	}
+	return best
+}
+
+func countCSVRecords(path string, comma rune) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader, err := newCSVReader(file)
+	if err != nil {
+		return 0, err
+	}
+	reader.Comma = comma
+	reader.ReuseRecord = true
+
+	count := -1 // Don't count the header
+	for {
+		_, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return max(count, 0), nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		count++
+	}
+}
+
+// parseLooseTime accepts the export's own format plus the formats
+// spreadsheets and the Twitter API commonly produce
+func parseLooseTime(value string) (time.Time, error) {
+	if t, err := parseTwitterTime(value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.RubyDate, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time format %q", value)
+}
+
+// TagSeparators are the characters splitTags splits a tags cell on
+const TagSeparators = ",;|"
+
+// splitTags accepts a JSON array of names, which the CSV export writes when a
+// name contains a separator, or tags separated by commas, semicolons or pipes
+func splitTags(value string) ([]string, error) {
+	if strings.HasPrefix(value, "[") {
+		var tags []string
+		if err := json.Unmarshal([]byte(value), &tags); err != nil {
+			return nil, err
+		}
+		return tags, nil
+	}
+
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return strings.ContainsRune(TagSeparators, r)
+	})
+
+	var tags []string
+	for _, tag := range fields {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
@@ -0,0 +1,143 @@
+// Package importers turns uploaded bookmark exports into models.Bookmark and
+// models.Media records. Each supported format implements Importer and is
+// picked from the registry by sniffing the uploaded files.
+package importers
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode"
+
+	"github.com/helioLJ/tweetvault/internal/models"
+)
+
+// ErrUnrecognized is returned when no registered importer accepts an upload
+var ErrUnrecognized = errors.New("unrecognized upload")
+
+// Upload is the set of files received for one import
+type Upload struct {
+	DataPath     string            // Uploaded data file (JSON, CSV...); empty when none was uploaded
+	DataFileName string            // Original name of the data file
+	Archive      *Archive          // Uploaded ZIP, nil when none was uploaded
+	Options      map[string]string // Importer-specific options, e.g. CSVMappingOption
+}
+
+// Item is a bookmark mapped from an export, with the media files it references
+type Item struct {
+	Bookmark    models.Bookmark
+	Media       []Media
+	Tags        []string // Tag names to add to the bookmark
+	InvalidDate *models.InvalidDate
+	Err         error // Set when the entry couldn't be mapped; it is recorded as a failure
+}
+
+// Media is a media record without its file data, plus the archive entry the
+// data is read from
+type Media struct {
+	Media models.Media
+	Entry string
+}
+
+// Source yields the bookmarks of an upload one at a time
+type Source interface {
+	Next(item *Item) (bool, error)
+	Close() error
+}
+
+// Importer understands one export format
+type Importer interface {
+	// Name identifies the importer in job reports and the format parameter
+	Name() string
+	// Detect reports whether the upload looks like this importer's format.
+	// It must be cheap: sniff headers and archive entry names only.
+	Detect(upload *Upload) bool
+	// Open starts reading the upload and returns how many bookmarks it
+	// holds, or 0 when the Source estimates it with Progress instead
+	Open(upload *Upload) (Source, int, error)
+}
+
+// ProgressSource is implemented by sources that don't count their bookmarks
+// up front. Progress is the fraction of the upload read so far, from 0 to 1.
+type ProgressSource interface {
+	Source
+	Progress() float64
+}
+
+// registry holds importers in detection order: the most specific first
+var registry []Importer
+
+func init() {
+	Register(xArchiveImporter{})
+	Register(webExporterImporter{})
+	Register(csvImporter{})
+}
+
+// Register adds an importer after the ones already registered
+func Register(importer Importer) {
+	registry = append(registry, importer)
+}
+
+// Get returns the importer with the given name
+func Get(name string) (Importer, bool) {
+	for _, importer := range registry {
+		if importer.Name() == name {
+			return importer, true
+		}
+	}
+	return nil, false
+}
+
+// Names lists the registered importers in detection order
+func Names() []string {
+	names := make([]string, len(registry))
+	for i, importer := range registry {
+		names[i] = importer.Name()
+	}
+	return names
+}
+
+// Detect returns the first registered importer that accepts the upload
+func Detect(upload *Upload) (Importer, error) {
+	for _, importer := range registry {
+		if importer.Detect(upload) {
+			return importer, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: expected one of %v", ErrUnrecognized, Names())
+}
+
+// PeekData returns up to n bytes of the data file after any byte order mark
+// and leading whitespace, or nil when there is no data file
+func PeekData(upload *Upload, n int) []byte {
+	if upload.DataPath == "" {
+		return nil
+	}
+
+	file, err := os.Open(upload.DataPath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			return nil
+		}
+		if r != '\uFEFF' && !unicode.IsSpace(r) {
+			reader.UnreadRune()
+			break
+		}
+	}
+
+	buf := make([]byte, n)
+	read, err := io.ReadFull(reader, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil
+	}
+	return buf[:read]
+}
@@ -0,0 +1,63 @@
+package importers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// jsonArrayStream decodes a JSON array one element at a time, so only the
+// element currently being imported is held in memory
+type jsonArrayStream struct {
+	closer  io.Closer
+	decoder *json.Decoder
+}
+
+// newJSONArrayStream consumes the opening bracket of the array in r. The
+// stream takes ownership of r and closes it, including on error.
+func newJSONArrayStream(r io.ReadCloser) (*jsonArrayStream, error) {
+	reader := bufio.NewReader(r)
+
+	// Editors on Windows like to prepend a byte order mark
+	if bom, _ := reader.Peek(3); string(bom) == "\xEF\xBB\xBF" {
+		reader.Discard(3)
+	}
+
+	return newJSONArrayStreamFrom(r, reader)
+}
+
+func newJSONArrayStreamFrom(closer io.Closer, r io.Reader) (*jsonArrayStream, error) {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		closer.Close()
+		return nil, fmt.Errorf("expected a JSON array, got %v", token)
+	}
+
+	return &jsonArrayStream{closer: closer, decoder: decoder}, nil
+}
+
+// Next decodes the next element into v, returning false at the end of the array
+func (s *jsonArrayStream) Next(v interface{}) (bool, error) {
+	if !s.decoder.More() {
+		// Consume the closing bracket so truncated files are reported
+		if _, err := s.decoder.Token(); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+
+	if err := s.decoder.Decode(v); err != nil {
+		return false, fmt.Errorf("element at offset %d: %w", s.decoder.InputOffset(), err)
+	}
+	return true, nil
+}
+
+func (s *jsonArrayStream) Close() error {
+	return s.closer.Close()
+}
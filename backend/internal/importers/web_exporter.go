@@ -0,0 +1,234 @@
+package importers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/helioLJ/tweetvault/internal/models"
+)
+
+// webExporterImporter reads the JSON array written by the twitter-web-exporter
+// browser extension, with media in a separate ZIP
+type webExporterImporter struct{}
+
+func (webExporterImporter) Name() string {
+	return "twitter-web-exporter"
+}
+
+func (webExporterImporter) Detect(upload *Upload) bool {
+	return string(PeekData(upload, 1)) == "["
+}
+
+// Open doesn't count the bookmarks, which would decode a large export twice;
+// the source reports its progress through the file instead
+func (webExporterImporter) Open(upload *Upload) (Source, int, error) {
+	source, err := openWebExporterSource(upload.DataPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	return source, 0, nil
+}
+
+type TwitterBookmark struct {
+	ID              string          `json:"id"`
+	CreatedAt       string          `json:"created_at"`
+	FullText        string          `json:"full_text"`
+	ScreenName      string          `json:"screen_name"`
+	Name            string          `json:"name"`
+	ProfileImageURL string          `json:"profile_image_url"`
+	InReplyTo       *string         `json:"in_reply_to"`
+	RetweetedStatus *string         `json:"retweeted_status"`
+	QuotedStatus    *string         `json:"quoted_status"`
+	FavoriteCount   int             `json:"favorite_count"`
+	RetweetCount    int             `json:"retweet_count"`
+	BookmarkCount   int             `json:"bookmark_count"`
+	QuoteCount      int             `json:"quote_count"`
+	ReplyCount      int             `json:"reply_count"`
+	ViewsCount      int             `json:"views_count"`
+	Favorited       bool            `json:"favorited"`
+	Retweeted       bool            `json:"retweeted"`
+	Bookmarked      bool            `json:"bookmarked"`
+	URL             string          `json:"url"`
+	Metadata        json.RawMessage `json:"metadata"`
+	Media           []TwitterMedia  `json:"media"`
+}
+
+// TwitterMedia is a media entry as twitter-web-exporter writes it
+type TwitterMedia struct {
+	Type      string `json:"type"`
+	URL       string `json:"url"`
+	Thumbnail string `json:"thumbnail"`
+	Original  string `json:"original"`
+}
+
+// webExporterSource streams the bookmarks of a twitter-web-exporter file
+type webExporterSource struct {
+	stream *jsonArrayStream
+	size   int64
+}
+
+func openWebExporterSource(path string) (*webExporterSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	stream, err := newJSONArrayStream(file)
+	if err != nil {
+		return nil, err
+	}
+	return &webExporterSource{stream: stream, size: info.Size()}, nil
+}
+
+func (s *webExporterSource) Next(item *Item) (bool, error) {
+	var tb TwitterBookmark
+	more, err := s.stream.Next(&tb)
+	if err != nil || !more {
+		return false, err
+	}
+
+	*item = tb.toItem()
+	return true, nil
+}
+
+// Progress is how far the decoder has read into the file
+func (s *webExporterSource) Progress() float64 {
+	if s.size == 0 {
+		return 1
+	}
+	return min(1, float64(s.stream.decoder.InputOffset())/float64(s.size))
+}
+
+func (s *webExporterSource) Close() error {
+	return s.stream.Close()
+}
+
+func (tb *TwitterBookmark) toItem() Item {
+	var item Item
+
+	createdAt, err := parseTwitterTime(tb.CreatedAt)
+	if err != nil {
+		createdAt = snowflakeTime(tb.ID)
+		item.InvalidDate = &models.InvalidDate{
+			BookmarkID: tb.ID,
+			Value:      tb.CreatedAt,
+			UsedValue:  createdAt,
+		}
+	}
+
+	item.Bookmark = models.Bookmark{
+		ID:              tb.ID,
+		CreatedAt:       createdAt,
+		FullText:        tb.FullText,
+		ScreenName:      tb.ScreenName,
+		Name:            tb.Name,
+		ProfileImageURL: tb.ProfileImageURL,
+		FavoriteCount:   tb.FavoriteCount,
+		RetweetCount:    tb.RetweetCount,
+		BookmarkCount:   tb.BookmarkCount,
+		QuoteCount:      tb.QuoteCount,
+		ReplyCount:      tb.ReplyCount,
+		ViewsCount:      tb.ViewsCount,
+		Favorited:       tb.Favorited,
+		Retweeted:       tb.Retweeted,
+		Bookmarked:      tb.Bookmarked,
+		URL:             tb.URL,
+		Metadata:        tb.Metadata,
+	}
+
+	item.Media = mediaItems(tb.ScreenName, tb.ID, tb.Media)
+
+	return item
+}
+
+// mediaItems names media the way twitter-web-exporter names files in its ZIP
+func mediaItems(screenName, tweetID string, media []TwitterMedia) []Media {
+	var items []Media
+	for i, m := range media {
+		mediaFileName := generateMediaFileName(screenName, tweetID, m.Type, i+1)
+		items = append(items, Media{
+			Media: models.Media{
+				Type:      m.Type,
+				URL:       m.URL,
+				Thumbnail: m.Thumbnail,
+				Original:  m.Original,
+				FileName:  mediaFileName,
+			},
+			Entry: mediaFileName,
+		})
+	}
+	return items
+}
+
+func parseTwitterTime(timeStr string) (time.Time, error) {
+	// Try parsing with timezone offset format (with space before timezone)
+	t, err := time.Parse("2006-01-02 15:04:05 -0700", timeStr)
+	if err == nil {
+		return t, nil
+	}
+
+	// Try parsing with timezone offset format (without space)
+	t, err = time.Parse("2006-01-02 15:04:05-0700", timeStr)
+	if err == nil {
+		return t, nil
+	}
+
+	// Try parsing with timezone offset format (with space and colon in timezone)
+	t, err = time.Parse("2006-01-02 15:04:05 -07:00", timeStr)
+	if err == nil {
+		return t, nil
+	}
+
+	// Fallback to parsing without timezone
+	t, err = time.Parse("2006-01-02 15:04:05", timeStr)
+	if err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized time format %q", timeStr)
+}
+
+// snowflakeTime extracts the creation time embedded in a tweet ID, falling
+// back to the current time for IDs that aren't snowflakes
+func snowflakeTime(tweetID string) time.Time {
+	tweetIDInt, err := strconv.ParseInt(tweetID, 10, 64)
+	if err != nil || tweetIDInt <= 0 {
+		return time.Now()
+	}
+	return time.UnixMilli((tweetIDInt >> 22) + 1288834974657)
+}
+
+func generateMediaFileName(screenName, tweetID, mediaType string, index int) string {
+	// Extract creation date from tweet ID to match the file naming convention
+	// Twitter IDs contain a timestamp that we can use
+	tweetIDInt, _ := strconv.ParseInt(tweetID, 10, 64)
+	timestamp := time.Unix((tweetIDInt>>22)/1000+1288834974657/1000, 0)
+	dateStr := timestamp.Format("20060102") // Format as YYYYMMDD
+
+	return fmt.Sprintf("%s_%s_%s_%d_%s%s",
+		screenName,
+		tweetID,
+		mediaType,
+		index,
+		dateStr,
+		getExtensionForMediaType(mediaType))
+}
+
+func getExtensionForMediaType(mediaType string) string {
+	switch mediaType {
+	case "video":
+		return ".mp4"
+	case "photo":
+		return ".jpg"
+	default:
+		return ""
+	}
+}
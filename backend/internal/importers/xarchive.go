@@ -0,0 +1,574 @@
+package importers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helioLJ/tweetvault/internal/models"
+)
+
+// The official X account archive stores each data file as a JavaScript
+// assignment such as "window.YTD.tweets.part0 = [...]", split into numbered
+// parts for large accounts, and keeps media under data/tweets_media.
+var xArchivePartPattern = regexp.MustCompile(`^(.*?)data/(tweets|bookmarks?)(?:-part(\d+))?\.js$`)
+
+var xStatusURLPattern = regexp.MustCompile(`^https?://(?:www\.|mobile\.)?(?:twitter|x)\.com/([A-Za-z0-9_]+)/status/`)
+
+// xArchiveImporter reads the official X account archive, a single ZIP
+type xArchiveImporter struct{}
+
+func (xArchiveImporter) Name() string {
+	return "x-archive"
+}
+
+func (xArchiveImporter) Detect(upload *Upload) bool {
+	return upload.Archive != nil && detectXArchive(upload.Archive) != nil
+}
+
+func (xArchiveImporter) Open(upload *Upload) (Source, int, error) {
+	layout := detectXArchive(upload.Archive)
+	if layout == nil {
+		return nil, 0, errors.New("no data/tweets.js or data/bookmarks.js in the archive")
+	}
+
+	source, err := openXArchiveSource(upload.Archive, layout)
+	if err != nil {
+		return nil, 0, err
+	}
+	// Bookmarks are loaded up front; tweets are streamed, and their total is
+	// estimated from Progress as they are read
+	return source, len(source.bookmarks), nil
+}
+
+// xArchiveLayout describes where the interesting files of an X archive live
+type xArchiveLayout struct {
+	root      string   // Prefix before data/, when the archive was re-zipped inside a folder
+	tweets    []string // data/tweets.js and its parts, in order
+	bookmarks []string // data/bookmarks.js and its parts, in order
+}
+
+// detectXArchive reports the archive layout, or nil when the ZIP is not an X archive
+func detectXArchive(archive *Archive) *xArchiveLayout {
+	type part struct {
+		name  string
+		index int
+	}
+	var tweets, bookmarks []part
+	root := ""
+
+	for _, file := range archive.Files() {
+		match := xArchivePartPattern.FindStringSubmatch(file.Name)
+		if match == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(match[3])
+		root = match[1]
+		if match[2] == "tweets" {
+			tweets = append(tweets, part{file.Name, index})
+		} else {
+			bookmarks = append(bookmarks, part{file.Name, index})
+		}
+	}
+
+	if len(tweets) == 0 && len(bookmarks) == 0 {
+		return nil
+	}
+
+	names := func(parts []part) []string {
+		sort.Slice(parts, func(i, j int) bool { return parts[i].index < parts[j].index })
+		result := make([]string, len(parts))
+		for i, p := range parts {
+			result[i] = p.name
+		}
+		return result
+	}
+
+	return &xArchiveLayout{root: root, tweets: names(tweets), bookmarks: names(bookmarks)}
+}
+
+// xTweet is the subset of an archived tweet that maps onto models.Bookmark
+type xTweet struct {
+	ID                string  `json:"id_str"`
+	CreatedAt         string  `json:"created_at"`
+	FullText          string  `json:"full_text"`
+	FavoriteCount     flexInt `json:"favorite_count"`
+	RetweetCount      flexInt `json:"retweet_count"`
+	InReplyToStatusID string  `json:"in_reply_to_status_id_str"`
+	Favorited         bool    `json:"favorited"`
+	Retweeted         bool    `json:"retweeted"`
+	ExtendedEntities  struct {
+		Media []xMedia `json:"media"`
+	} `json:"extended_entities"`
+}
+
+type xMedia struct {
+	ID            string `json:"id_str"`
+	Type          string `json:"type"` // photo, video, animated_gif
+	URL           string `json:"url"`
+	MediaURLHTTPS string `json:"media_url_https"`
+	VideoInfo     struct {
+		Variants []struct {
+			Bitrate     flexInt `json:"bitrate"`
+			ContentType string  `json:"content_type"`
+			URL         string  `json:"url"`
+		} `json:"variants"`
+	} `json:"video_info"`
+}
+
+// xBookmark is an entry of data/bookmarks.js, which only references the tweet
+type xBookmark struct {
+	TweetID     string `json:"tweetId"`
+	FullText    string `json:"fullText"`
+	ExpandedURL string `json:"expandedUrl"`
+}
+
+// xAccount is the archive owner, who authored everything in data/tweets.js
+type xAccount struct {
+	Username        string `json:"username"`
+	DisplayName     string `json:"accountDisplayName"`
+	ProfileImageURL string `json:"-"`
+}
+
+// flexInt accepts counts encoded as JSON numbers or as strings, the latter
+// being how the X archive stores them
+type flexInt int
+
+func (n *flexInt) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid count %s: %w", data, err)
+	}
+	*n = flexInt(v)
+	return nil
+}
+
+// xArchiveSource imports an X archive. When the archive has bookmarks those
+// are imported, enriched with the archived tweet when it's one of the owner's;
+// otherwise the owner's own tweets are imported.
+type xArchiveSource struct {
+	archive *Archive
+	layout  *xArchiveLayout
+	owner   xAccount
+	media   map[string][]string // Tweet ID to its entries under data/tweets_media
+
+	// Bookmarks mode
+	bookmarks []xBookmark
+	tweets    map[string]json.RawMessage
+	next      int
+
+	// Tweets mode
+	part   int
+	stream *jsonArrayStream
+	size   int64 // Uncompressed size of all tweet parts
+	done   int64 // Uncompressed size of the parts already read
+}
+
+func openXArchiveSource(archive *Archive, layout *xArchiveLayout) (*xArchiveSource, error) {
+	s := &xArchiveSource{
+		archive: archive,
+		layout:  layout,
+		media:   make(map[string][]string),
+	}
+
+	if err := s.loadOwner(); err != nil {
+		return nil, err
+	}
+
+	mediaPrefix := layout.root + "data/tweets_media/"
+	for _, file := range archive.Files() {
+		if !strings.HasPrefix(file.Name, mediaPrefix) {
+			continue
+		}
+		base := path.Base(file.Name)
+		if tweetID, _, ok := strings.Cut(base, "-"); ok {
+			s.media[tweetID] = append(s.media[tweetID], file.Name)
+		}
+	}
+
+	if len(layout.bookmarks) > 0 {
+		if err := s.loadBookmarks(); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range layout.tweets {
+		s.size += s.partSize(name)
+	}
+
+	return s, nil
+}
+
+// Progress is the fraction of bookmarks yielded, or of the tweet parts'
+// bytes decoded so far
+func (s *xArchiveSource) Progress() float64 {
+	if len(s.layout.bookmarks) > 0 {
+		if len(s.bookmarks) == 0 {
+			return 1
+		}
+		return float64(s.next) / float64(len(s.bookmarks))
+	}
+	if s.size == 0 {
+		return 1
+	}
+	read := s.done
+	if s.stream != nil {
+		read += s.stream.decoder.InputOffset()
+	}
+	return min(1, float64(read)/float64(s.size))
+}
+
+// partSize returns the uncompressed size of an archive entry
+func (s *xArchiveSource) partSize(name string) int64 {
+	if file, ok := s.archive.entries[name]; ok {
+		return int64(file.UncompressedSize64)
+	}
+	return 0
+}
+
+func (s *xArchiveSource) Next(item *Item) (bool, error) {
+	if len(s.layout.bookmarks) > 0 {
+		return s.nextBookmark(item)
+	}
+	return s.nextTweet(item)
+}
+
+func (s *xArchiveSource) Close() error {
+	if s.stream != nil {
+		return s.stream.Close()
+	}
+	return nil
+}
+
+func (s *xArchiveSource) nextBookmark(item *Item) (bool, error) {
+	if s.next >= len(s.bookmarks) {
+		return false, nil
+	}
+	bookmark := s.bookmarks[s.next]
+	s.next++
+
+	if raw, ok := s.tweets[bookmark.TweetID]; ok {
+		return true, s.mapTweet(raw, item)
+	}
+
+	// Someone else's tweet: the archive only knows its ID, text and link
+	screenName := ""
+	if match := xStatusURLPattern.FindStringSubmatch(bookmark.ExpandedURL); match != nil {
+		screenName = match[1]
+	}
+	createdAt := snowflakeTime(bookmark.TweetID)
+
+	*item = Item{
+		Bookmark: models.Bookmark{
+			ID:         bookmark.TweetID,
+			CreatedAt:  createdAt,
+			FullText:   bookmark.FullText,
+			ScreenName: screenName,
+			URL:        statusURL(screenName, bookmark.TweetID),
+			Bookmarked: true,
+		},
+	}
+	return true, nil
+}
+
+func (s *xArchiveSource) nextTweet(item *Item) (bool, error) {
+	for {
+		if s.stream == nil {
+			if s.part >= len(s.layout.tweets) {
+				return false, nil
+			}
+			stream, err := s.openPart(s.layout.tweets[s.part])
+			if err != nil {
+				return false, err
+			}
+			s.stream = stream
+		}
+
+		var entry struct {
+			Tweet json.RawMessage `json:"tweet"`
+		}
+		more, err := s.stream.Next(&entry)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", s.layout.tweets[s.part], err)
+		}
+		if more {
+			return true, s.mapTweet(entry.Tweet, item)
+		}
+
+		s.stream.Close()
+		s.stream = nil
+		s.done += s.partSize(s.layout.tweets[s.part])
+		s.part++
+	}
+}
+
+// mapTweet converts one of the owner's archived tweets into an import item
+func (s *xArchiveSource) mapTweet(raw json.RawMessage, item *Item) error {
+	var tweet xTweet
+	if err := json.Unmarshal(raw, &tweet); err != nil {
+		return err
+	}
+
+	*item = Item{}
+
+	createdAt, err := time.Parse(time.RubyDate, tweet.CreatedAt)
+	if err != nil {
+		createdAt = snowflakeTime(tweet.ID)
+		item.InvalidDate = &models.InvalidDate{
+			BookmarkID: tweet.ID,
+			Value:      tweet.CreatedAt,
+			UsedValue:  createdAt,
+		}
+	}
+
+	item.Bookmark = models.Bookmark{
+		ID:              tweet.ID,
+		CreatedAt:       createdAt,
+		FullText:        tweet.FullText,
+		ScreenName:      s.owner.Username,
+		Name:            s.owner.DisplayName,
+		ProfileImageURL: s.owner.ProfileImageURL,
+		FavoriteCount:   int(tweet.FavoriteCount),
+		RetweetCount:    int(tweet.RetweetCount),
+		Favorited:       tweet.Favorited,
+		Retweeted:       tweet.Retweeted,
+		Bookmarked:      len(s.layout.bookmarks) > 0,
+		URL:             statusURL(s.owner.Username, tweet.ID),
+		Metadata:        raw,
+	}
+	if tweet.InReplyToStatusID != "" {
+		item.Bookmark.InReplyTo.String = tweet.InReplyToStatusID
+		item.Bookmark.InReplyTo.Valid = true
+	}
+
+	for _, m := range tweet.ExtendedEntities.Media {
+		item.Media = append(item.Media, s.mapMedia(tweet.ID, m))
+	}
+
+	return nil
+}
+
+func (s *xArchiveSource) mapMedia(tweetID string, m xMedia) Media {
+	original := m.MediaURLHTTPS + "?name=orig"
+	candidates := []string{urlBase(m.MediaURLHTTPS)}
+
+	if m.Type != "photo" {
+		// Prefer the highest bitrate MP4, but accept whichever variant was archived
+		variants := m.VideoInfo.Variants
+		sort.SliceStable(variants, func(i, j int) bool { return variants[i].Bitrate > variants[j].Bitrate })
+
+		var videos []string
+		original = m.MediaURLHTTPS
+		for _, variant := range variants {
+			if variant.ContentType != "video/mp4" {
+				continue
+			}
+			if len(videos) == 0 {
+				original = variant.URL
+			}
+			videos = append(videos, urlBase(variant.URL))
+		}
+		if len(videos) > 0 {
+			candidates = videos
+		}
+	}
+
+	// Archived media is named "<tweet id>-<original file name>"
+	fileName := tweetID + "-" + candidates[0]
+	entry := s.layout.root + "data/tweets_media/" + fileName
+	for _, candidate := range candidates {
+		if archived := s.findMedia(tweetID, tweetID+"-"+candidate); archived != "" {
+			fileName, entry = path.Base(archived), archived
+			break
+		}
+	}
+
+	return Media{
+		Media: models.Media{
+			Type:      m.Type,
+			URL:       m.URL,
+			Thumbnail: m.MediaURLHTTPS + "?name=thumb",
+			Original:  original,
+			FileName:  fileName,
+		},
+		Entry: entry,
+	}
+}
+
+func (s *xArchiveSource) findMedia(tweetID, fileName string) string {
+	for _, entry := range s.media[tweetID] {
+		if path.Base(entry) == fileName {
+			return entry
+		}
+	}
+	return ""
+}
+
+// loadOwner reads the archive owner from data/account.js and data/profile.js.
+// Both are optional; tweets simply lack an author when they are missing.
+func (s *xArchiveSource) loadOwner() error {
+	var accounts []struct {
+		Account xAccount `json:"account"`
+	}
+	if err := s.readDataFile("account.js", &accounts); err != nil {
+		return err
+	}
+	if len(accounts) > 0 {
+		s.owner = accounts[0].Account
+	}
+
+	var profiles []struct {
+		Profile struct {
+			AvatarMediaURL string `json:"avatarMediaUrl"`
+		} `json:"profile"`
+	}
+	if err := s.readDataFile("profile.js", &profiles); err != nil {
+		return err
+	}
+	if len(profiles) > 0 {
+		s.owner.ProfileImageURL = profiles[0].Profile.AvatarMediaURL
+	}
+
+	return nil
+}
+
+// loadBookmarks reads every bookmark entry, then keeps the archived tweets
+// they reference so bookmarks of the owner's own tweets get full details
+func (s *xArchiveSource) loadBookmarks() error {
+	wanted := make(map[string]bool)
+	for _, name := range s.layout.bookmarks {
+		stream, err := s.openPart(name)
+		if err != nil {
+			return err
+		}
+		for {
+			var entry struct {
+				Bookmark xBookmark `json:"bookmark"`
+			}
+			more, err := stream.Next(&entry)
+			if err != nil {
+				stream.Close()
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if !more {
+				break
+			}
+			if entry.Bookmark.TweetID == "" {
+				continue
+			}
+			s.bookmarks = append(s.bookmarks, entry.Bookmark)
+			wanted[entry.Bookmark.TweetID] = true
+		}
+		stream.Close()
+	}
+
+	s.tweets = make(map[string]json.RawMessage)
+	for _, name := range s.layout.tweets {
+		stream, err := s.openPart(name)
+		if err != nil {
+			return err
+		}
+		for {
+			var entry struct {
+				Tweet json.RawMessage `json:"tweet"`
+			}
+			more, err := stream.Next(&entry)
+			if err != nil {
+				stream.Close()
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if !more {
+				break
+			}
+			var id struct {
+				ID string `json:"id_str"`
+			}
+			if err := json.Unmarshal(entry.Tweet, &id); err == nil && wanted[id.ID] {
+				s.tweets[id.ID] = entry.Tweet
+			}
+		}
+		stream.Close()
+	}
+
+	return nil
+}
+
+// readDataFile decodes a small data/*.js file completely, ignoring missing files
+func (s *xArchiveSource) readDataFile(name string, v interface{}) error {
+	entry := s.layout.root + "data/" + name
+	if !s.archive.Has(entry) {
+		return nil
+	}
+
+	stream, err := s.openPart(entry)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	var values []json.RawMessage
+	for {
+		var value json.RawMessage
+		more, err := stream.Next(&value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if !more {
+			break
+		}
+		values = append(values, value)
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// openPart streams the array assigned in an archive data file
+func (s *xArchiveSource) openPart(name string) (*jsonArrayStream, error) {
+	rc, err := s.archive.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(rc)
+	if _, err := reader.ReadString('='); err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("%s: missing window.YTD assignment: %w", name, err)
+	}
+
+	stream, err := newJSONArrayStreamFrom(rc, reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return stream, nil
+}
+
+func statusURL(screenName, tweetID string) string {
+	if screenName == "" {
+		return "https://x.com/i/web/status/" + tweetID
+	}
+	return fmt.Sprintf("https://x.com/%s/status/%s", screenName, tweetID)
+}
+
+// urlBase returns the last path segment of a URL without its query string
+func urlBase(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return path.Base(u.Path)
+	}
+	return path.Base(rawURL)
+}
@@ -0,0 +1,38 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/helioLJ/tweetvault/internal/services"
+)
+
+// StartImportWorker polls for queued import jobs and runs them one at a time
+func StartImportWorker(importService *services.ImportService) {
+	go func() {
+		if err := importService.RequeueInterrupted(); err != nil {
+			log.Printf("Error requeueing interrupted imports: %v", err)
+		}
+
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			// Drain the queue before waiting for the next tick
+			for {
+				job, err := importService.ClaimNext()
+				if err != nil {
+					log.Printf("Error claiming import job: %v", err)
+					break
+				}
+				if job == nil {
+					break
+				}
+
+				log.Printf("Starting import job %d", job.ID)
+				importService.Run(job)
+				log.Printf("Finished import job %d", job.ID)
+			}
+		}
+	}()
+}
@@ -29,6 +29,7 @@ type Bookmark struct {
 	Metadata        json.RawMessage `gorm:"type:jsonb" json:"metadata"`
 	Media           []Media         `gorm:"foreignKey:TweetID" json:"media"`
 	Tags            []Tag           `gorm:"many2many:bookmark_tags" json:"tags"`
+	ImportedAt      time.Time       `gorm:"autoCreateTime" json:"imported_at"` // When the bookmark was first imported
 	UpdatedAt       time.Time       `gorm:"autoUpdateTime" json:"-"`
 	Archived        bool            `json:"archived" gorm:"default:false;index:idx_archived_createdAt"`
 }
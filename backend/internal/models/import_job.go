@@ -0,0 +1,152 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Import job phases, in the order a job moves through them
+const (
+	ImportQueued    = "queued"
+	ImportParsing   = "parsing"
+	ImportImporting = "importing"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+	ImportCancelled = "cancelled"
+)
+
+// Merge policies decide what happens to bookmarks that already exist. None of
+// them touch user-owned state: archive status, tags and completion flags. Tags
+// carried by an export (CSV) are added to, never removed from, a bookmark.
+const (
+	MergeSkip      = "skip"      // Leave existing bookmarks untouched
+	MergeMetrics   = "metrics"   // Only refresh engagement counts
+	MergeOverwrite = "overwrite" // Replace every imported field
+)
+
+type ImportJob struct {
+	ID              uint            `gorm:"primaryKey" json:"id"`
+	Phase           string          `gorm:"type:varchar(20);index;not null" json:"phase"`
+	Format          string          `gorm:"type:varchar(30)" json:"format"`          // Name of the importer that reads the upload
+	DataFileName    string          `gorm:"type:varchar(255)" json:"data_file_name"` // Original name of the JSON or CSV file
+	ZipFileName     string          `gorm:"type:varchar(255)" json:"zip_file_name"`
+	StoragePath     string          `gorm:"type:text" json:"-"` // Directory holding the uploaded files until the job finishes
+	Total           int             `json:"total"`              // Estimated while importing formats that aren't counted up front
+	Processed       int             `json:"processed"`
+	FailedCount     int             `json:"failed_count"`
+	Error           string          `gorm:"type:text" json:"error,omitempty"`
+	CancelRequested bool            `gorm:"default:false" json:"cancel_requested"`
+	DryRun          bool            `gorm:"default:false" json:"dry_run"`
+	MergePolicy     string          `gorm:"type:varchar(20);default:overwrite" json:"merge_policy"`
+	ImporterOptions StringMap       `gorm:"type:jsonb" json:"importer_options,omitempty"`
+	Report          ImportReport    `gorm:"type:jsonb" json:"report"`
+	StartedAt       *time.Time      `json:"started_at"`
+	FinishedAt      *time.Time      `json:"finished_at"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+	Failures        []ImportFailure `gorm:"foreignKey:ImportJobID;constraint:OnDelete:CASCADE" json:"failures,omitempty"`
+}
+
+// ImportFailure records a single bookmark that could not be imported
+type ImportFailure struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ImportJobID uint      `gorm:"index;not null" json:"import_job_id"`
+	BookmarkID  string    `gorm:"type:varchar(30)" json:"bookmark_id"`
+	Error       string    `gorm:"type:text" json:"error"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ImportReport describes what an import did, or would do for a dry run.
+// The detail lists are capped; the counters always cover every bookmark.
+type ImportReport struct {
+	MergePolicy  string           `json:"merge_policy"`
+	New          int              `json:"new"`
+	Existing     int              `json:"existing"`
+	Skipped      int              `json:"skipped"` // Existing bookmarks left untouched by the merge policy
+	Changed      int              `json:"changed"`
+	FieldChanges map[string]int   `json:"field_changes"` // Number of bookmarks in which each field changes
+	Changes      []BookmarkChange `json:"changes"`
+	MissingMedia []MissingMedia   `json:"missing_media"`
+	InvalidDates []InvalidDate    `json:"invalid_dates"`
+	Truncated    bool             `json:"truncated"`
+}
+
+type BookmarkChange struct {
+	BookmarkID string        `json:"bookmark_id"`
+	Fields     []FieldChange `json:"fields"`
+}
+
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// MissingMedia is a media file referenced by a bookmark but absent from the ZIP
+type MissingMedia struct {
+	BookmarkID string `json:"bookmark_id"`
+	FileName   string `json:"file_name"`
+}
+
+// InvalidDate is a created_at value that could not be parsed; the bookmark
+// falls back to the timestamp embedded in its tweet ID
+type InvalidDate struct {
+	BookmarkID string    `json:"bookmark_id"`
+	Value      string    `json:"value"`
+	UsedValue  time.Time `json:"used_value"`
+}
+
+// Value stores the report as JSONB
+func (r ImportReport) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+// Scan loads the report from JSONB
+func (r *ImportReport) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*r = ImportReport{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	default:
+		return fmt.Errorf("cannot scan %T into ImportReport", value)
+	}
+}
+
+// Finished reports whether the job has reached a terminal phase
+func (j *ImportJob) Finished() bool {
+	return j.Phase == ImportCompleted || j.Phase == ImportFailed || j.Phase == ImportCancelled
+}
+
+// DataPath is where the uploaded data file (JSON or CSV) is stored while the
+// job is pending. It keeps the extension of the original name.
+func (j *ImportJob) DataPath() string {
+	return filepath.Join(j.StoragePath, "data"+dataFileExt(j.DataFileName))
+}
+
+// dataFileExt returns the lower-cased extension of an uploaded file name, or
+// nothing when it isn't a short alphanumeric one
+func dataFileExt(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if len(ext) < 2 || len(ext) > 8 {
+		return ""
+	}
+	for _, r := range ext[1:] {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return ""
+		}
+	}
+	return ext
+}
+
+// ZipPath is where the uploaded media ZIP is stored while the job is pending
+func (j *ImportJob) ZipPath() string {
+	return filepath.Join(j.StoragePath, "media.zip")
+}
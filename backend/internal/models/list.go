@@ -4,18 +4,31 @@ import (
 	"time"
 )
 
+// List is a curated, ordered collection of bookmarks. A bookmark can belong
+// to many lists.
 type List struct {
-	ID        uint       `gorm:"primaryKey" json:"id"`
-	Name      string     `gorm:"type:varchar(100);not null" json:"name"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
-	Bookmarks []Bookmark `gorm:"many2many:list_bookmarks;constraint:OnDelete:CASCADE" json:"bookmarks,omitempty"`
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Name          string     `gorm:"type:varchar(100);not null" json:"name"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	BookmarkCount int64      `gorm:"->;column:bookmark_count" json:"bookmark_count"` // Only set when selected
+	Bookmarks     []Bookmark `gorm:"many2many:list_bookmarks;constraint:OnDelete:CASCADE" json:"bookmarks,omitempty"`
 }
 
+// ListBookmark places a bookmark in a list. Positions start at 0 and are
+// kept contiguous.
 type ListBookmark struct {
 	ListID     uint      `gorm:"primaryKey" json:"list_id"`
 	BookmarkID string    `gorm:"primaryKey;type:varchar(30)" json:"bookmark_id"`
 	Position   int       `gorm:"not null" json:"position"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
-}
\ No newline at end of file
+}
+
+// ListMembership is a list a bookmark belongs to and its position there
+type ListMembership struct {
+	ID         uint   `json:"id"`
+	Name       string `json:"name"`
+	Position   int    `json:"position"`
+	BookmarkID string `json:"-"`
+}
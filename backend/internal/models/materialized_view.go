@@ -4,27 +4,33 @@ import "time"
 
 // BookmarkView represents the materialized view structure
 type BookmarkView struct {
-	ID              string          `gorm:"primaryKey;column:id" json:"id"`
-	CreatedAt       time.Time       `json:"created_at"`
-	FullText        string          `json:"full_text"`
-	ScreenName      string          `json:"screen_name"`
-	Name            string          `json:"name"`
-	ProfileImageURL string          `json:"profile_image_url"`
-	FavoriteCount   int             `json:"favorite_count"`
-	RetweetCount    int             `json:"retweet_count"`
-	ViewsCount      int             `json:"views_count"`
-	URL             string          `json:"url"`
-	Archived        bool            `json:"archived"`
-	Media           []Media         `gorm:"-" json:"media"`    // Will be populated from JSON
-	Tags            []TagWithStatus `gorm:"-" json:"tags"`     // Will be populated from JSON
-	MediaJSON       string          `gorm:"column:media_json"` // Stored as JSON string
-	TagsJSON        string          `gorm:"column:tags_json"`  // Stored as JSON string
+	ID              string           `gorm:"primaryKey;column:id" json:"id"`
+	CreatedAt       time.Time        `json:"created_at"`
+	FullText        string           `json:"full_text"`
+	ScreenName      string           `json:"screen_name"`
+	Name            string           `json:"name"`
+	ProfileImageURL string           `json:"profile_image_url"`
+	FavoriteCount   int              `json:"favorite_count"`
+	RetweetCount    int              `json:"retweet_count"`
+	ViewsCount      int              `json:"views_count"`
+	URL             string           `json:"url"`
+	Archived        bool             `json:"archived"`
+	ImportedAt      time.Time        `json:"imported_at"`
+	TaggedAt        *time.Time       `json:"tagged_at"`                                  // Most recent time a tag was added
+	Snippet         string           `gorm:"->;column:snippet" json:"snippet,omitempty"` // Highlighted match, only set when searching
+	Rank            float32          `gorm:"->;column:rank" json:"-"`                    // Full-text rank or similarity, only set when sorting by relevance
+	Media           []Media          `gorm:"-" json:"media"`                             // Will be populated from JSON
+	Tags            []TagWithStatus  `gorm:"-" json:"tags"`                              // Will be populated from JSON
+	Lists           []ListMembership `gorm:"-" json:"lists"`                             // Populated from list_bookmarks
+	MediaJSON       string           `gorm:"column:media_json"`                          // Stored as JSON string
+	TagsJSON        string           `gorm:"column:tags_json"`                           // Stored as JSON string
 }
 
 type TagWithStatus struct {
 	ID        uint   `json:"id"`
 	Name      string `json:"name"`
 	Completed bool   `json:"completed"`
+	TagMetadata
 }
 
 // TableName specifies the materialized view name
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// SavedSearch is a named bookmark list filter. The fields hold the same
+// values as the query parameters of GET /api/bookmarks.
+type SavedSearch struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Name        string     `gorm:"type:varchar(100);uniqueIndex;not null" json:"name"`
+	Search      string     `gorm:"type:text" json:"search"`
+	Fuzzy       bool       `gorm:"default:false" json:"fuzzy"`
+	Tags        StringList `gorm:"type:jsonb" json:"tags"`
+	TagMode     string     `gorm:"type:varchar(10)" json:"tag_mode"`
+	ExcludeTags StringList `gorm:"type:jsonb" json:"exclude_tags"`
+	Untagged    bool       `gorm:"default:false" json:"untagged"`
+	Archived    bool       `gorm:"default:false" json:"archived"`
+	Sort        string     `gorm:"type:varchar(20)" json:"sort"`
+	Order       string     `gorm:"type:varchar(4)" json:"order"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -1,17 +1,41 @@
 package models
 
 import (
+	"strings"
 	"time"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 type Tag struct {
 	ID           uint          `gorm:"primaryKey" json:"id"`
-	Name         string        `gorm:"type:varchar(50);unique" json:"name"`
+	Name         string        `gorm:"type:varchar(50)" json:"name"` // Normalized; unique ignoring case
+	ParentID     *uint         `gorm:"index" json:"parent_id"`       // Tags form a tree; nil for root tags
 	CreatedAt    time.Time     `json:"created_at"`
 	UpdatedAt    time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
 	Completed    bool          `json:"completed"`
 	Bookmarks    []Bookmark    `gorm:"many2many:bookmark_tags" json:"bookmarks,omitempty"`
 	BookmarkTags []BookmarkTag `gorm:"foreignKey:TagID" json:"-"`
+	Aliases      []TagAlias    `gorm:"foreignKey:TagID" json:"aliases,omitempty"`
+
+	TagMetadata `gorm:"embedded"`
+}
+
+// TagMetadata is how a tag is presented in the UI
+type TagMetadata struct {
+	Color       string `gorm:"type:varchar(7);not null;default:''" json:"color"` // "#rrggbb", empty for the default
+	Icon        string `gorm:"type:varchar(32);not null;default:''" json:"icon"` // Emoji or icon name
+	Description string `gorm:"type:text;not null;default:''" json:"description"`
+	Position    *int   `json:"position"` // Sidebar order; nil sorts after positioned tags
+}
+
+// TagAlias is an old name of a merged tag. Tagging a bookmark with the alias
+// adds the canonical tag instead.
+type TagAlias struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"type:varchar(50);not null" json:"name"` // Normalized; unique ignoring case
+	TagID     uint      `gorm:"index;not null" json:"tag_id"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type BookmarkTag struct {
@@ -20,3 +44,10 @@ type BookmarkTag struct {
 	CreatedAt  time.Time `json:"created_at"`
 	Completed  bool      `gorm:"default:false;index:idx_completed" json:"completed"`
 }
+
+// NormalizeTagName trims a tag name, collapses runs of whitespace into one
+// space and puts it in Unicode NFC form, so names that look the same are
+// stored the same
+func NormalizeTagName(name string) string {
+	return norm.NFC.String(strings.Join(strings.Fields(name), " "))
+}
@@ -0,0 +1,135 @@
+// Package searchquery parses the search language of the bookmarks list: free
+// words and quoted phrases matched with full-text search, plus operators such
+// as from:, tag:, has:, is:, before:, after: and min_faves:. Any term can be
+// negated with a leading '-'.
+package searchquery
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Operator keys
+const (
+	KeyFrom        = "from"
+	KeyTag         = "tag"
+	KeyHas         = "has"
+	KeyIs          = "is"
+	KeyBefore      = "before"
+	KeyAfter       = "after"
+	KeyMinFaves    = "min_faves"
+	KeyMinRetweets = "min_retweets"
+	KeyMinViews    = "min_views"
+)
+
+// Query is a parsed search: every term must match
+type Query struct {
+	Terms []Term
+}
+
+// Term is a Text or a Filter
+type Term interface {
+	// Pos is the byte offset of the term in the search string
+	Pos() int
+	term()
+}
+
+// Text is a word or quoted phrase matched against the full-text index
+type Text struct {
+	Position int
+	Value    string
+	Phrase   bool
+	Negated  bool
+}
+
+// Filter is a key:value operator. Date and Number hold the parsed value for
+// the date and count operators.
+type Filter struct {
+	Position int
+	Key      string
+	Value    string
+	Negated  bool
+	Date     time.Time
+	Number   int
+}
+
+func (t *Text) Pos() int   { return t.Position }
+func (f *Filter) Pos() int { return f.Position }
+func (*Text) term()        {}
+func (*Filter) term()      {}
+
+// Error is a syntax error at a byte offset of the search string
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("search syntax error at position %d: %s", e.Pos, e.Msg)
+}
+
+// Filters returns the operator terms in order
+func (q *Query) Filters() []*Filter {
+	var filters []*Filter
+	for _, term := range q.Terms {
+		if filter, ok := term.(*Filter); ok {
+			filters = append(filters, filter)
+		}
+	}
+	return filters
+}
+
+// Has reports whether the query contains the operator key:value, negated or not
+func (q *Query) Has(key, value string) bool {
+	for _, filter := range q.Filters() {
+		if filter.Key == key && filter.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// HasKey reports whether the query contains an operator with the key
+func (q *Query) HasKey(key string) bool {
+	for _, filter := range q.Filters() {
+		if filter.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// FullText renders the text terms in websearch_to_tsquery syntax, or returns
+// an empty string when the query has none
+func (q *Query) FullText() string {
+	var parts []string
+	for _, term := range q.Terms {
+		text, ok := term.(*Text)
+		if !ok {
+			continue
+		}
+
+		value := text.Value
+		if text.Phrase {
+			value = `"` + value + `"`
+		}
+		if text.Negated {
+			value = "-" + value
+		}
+		parts = append(parts, value)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Words returns the values of the text terms that must match, or with
+// negated set of those that must not, without quotes
+func (q *Query) Words(negated bool) []string {
+	var words []string
+	for _, term := range q.Terms {
+		if text, ok := term.(*Text); ok && text.Negated == negated {
+			words = append(words, text.Value)
+		}
+	}
+	return words
+}
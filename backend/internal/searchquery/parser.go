@@ -0,0 +1,187 @@
+package searchquery
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// hasValues and isValues list the values accepted by has: and is:
+var (
+	hasValues = []string{"video", "photo", "gif", "media"}
+	isValues  = []string{"archived", "reply", "quote"}
+)
+
+var screenNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,50}$`)
+
+// Parse turns a search string into a Query. Words whose prefix before ':' is
+// not an operator, such as URLs, are treated as text.
+func Parse(input string) (*Query, error) {
+	p := &parser{input: input}
+	query := &Query{}
+
+	for {
+		p.skipSpace()
+		if p.done() {
+			return query, nil
+		}
+
+		term, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		if term != nil {
+			query.Terms = append(query.Terms, term)
+		}
+	}
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *parser) peek() rune {
+	r, _ := utf8.DecodeRuneInString(p.input[p.pos:])
+	return r
+}
+
+func (p *parser) skipSpace() {
+	for !p.done() {
+		r, size := utf8.DecodeRuneInString(p.input[p.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.pos += size
+	}
+}
+
+// parseTerm reads one term: [-] ( "phrase" | key:value | key:"value" | word )
+func (p *parser) parseTerm() (Term, error) {
+	start := p.pos
+	negated := false
+	if p.peek() == '-' {
+		negated = true
+		p.pos++
+		if p.done() || unicode.IsSpace(p.peek()) {
+			return nil, nil // A lone dash, as in "well - known", is punctuation
+		}
+	}
+
+	if p.peek() == '"' {
+		phrase, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(phrase) == "" {
+			return nil, nil // An empty phrase matches everything
+		}
+		return &Text{Position: start, Value: phrase, Phrase: true, Negated: negated}, nil
+	}
+
+	wordStart := p.pos
+	word := p.bare()
+
+	if key, value, found := strings.Cut(word, ":"); found && isOperator(strings.ToLower(key)) {
+		valuePos := wordStart + len(key) + 1
+		if value == "" && !p.done() && p.peek() == '"' {
+			quoted, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			value = quoted
+		}
+		return newFilter(start, strings.ToLower(key), value, valuePos, negated)
+	}
+
+	return &Text{Position: start, Value: word, Negated: negated}, nil
+}
+
+// bare reads up to the next space or quote
+func (p *parser) bare() string {
+	start := p.pos
+	for !p.done() {
+		r, size := utf8.DecodeRuneInString(p.input[p.pos:])
+		if unicode.IsSpace(r) || r == '"' {
+			break
+		}
+		p.pos += size
+	}
+	return p.input[start:p.pos]
+}
+
+// quoted reads a double-quoted string starting at the current position
+func (p *parser) quoted() (string, error) {
+	start := p.pos
+	end := strings.IndexByte(p.input[start+1:], '"')
+	if end < 0 {
+		return "", &Error{Pos: start, Msg: "unterminated quote"}
+	}
+	p.pos = start + 1 + end + 1
+	return p.input[start+1 : start+1+end], nil
+}
+
+func isOperator(key string) bool {
+	switch key {
+	case KeyFrom, KeyTag, KeyHas, KeyIs, KeyBefore, KeyAfter, KeyMinFaves, KeyMinRetweets, KeyMinViews:
+		return true
+	}
+	return false
+}
+
+// newFilter validates an operator value, reporting errors at the value
+func newFilter(pos int, key, value string, valuePos int, negated bool) (*Filter, error) {
+	filter := &Filter{Position: pos, Key: key, Value: value, Negated: negated}
+	if value == "" {
+		return nil, &Error{Pos: valuePos, Msg: fmt.Sprintf("%s: needs a value", key)}
+	}
+
+	switch key {
+	case KeyFrom:
+		filter.Value = strings.TrimPrefix(value, "@")
+		if !screenNamePattern.MatchString(filter.Value) {
+			return nil, &Error{Pos: valuePos, Msg: fmt.Sprintf("from: %q is not a screen name", value)}
+		}
+	case KeyHas, KeyIs:
+		allowed := hasValues
+		if key == KeyIs {
+			allowed = isValues
+		}
+		filter.Value = strings.ToLower(value)
+		if !contains(allowed, filter.Value) {
+			return nil, &Error{Pos: valuePos, Msg: fmt.Sprintf("%s: %q must be one of %s",
+				key, value, strings.Join(allowed, ", "))}
+		}
+	case KeyBefore, KeyAfter:
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, &Error{Pos: valuePos, Msg: fmt.Sprintf("%s: %q is not a YYYY-MM-DD date", key, value)}
+		}
+		filter.Date = date
+	case KeyMinFaves, KeyMinRetweets, KeyMinViews:
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 0 {
+			return nil, &Error{Pos: valuePos, Msg: fmt.Sprintf("%s: %q is not a non-negative number", key, value)}
+		}
+		filter.Number = number
+	}
+
+	return filter, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
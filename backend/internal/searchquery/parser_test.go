@@ -0,0 +1,165 @@
+package searchquery
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []Term
+	}{
+		{"empty", "", nil},
+		{"spaces only", "   ", nil},
+		{"words", "go generics", []Term{
+			&Text{Position: 0, Value: "go"},
+			&Text{Position: 3, Value: "generics"},
+		}},
+		{"phrase", `"type parameters" go`, []Term{
+			&Text{Position: 0, Value: "type parameters", Phrase: true},
+			&Text{Position: 18, Value: "go"},
+		}},
+		{"empty phrase is dropped", `"" go`, []Term{
+			&Text{Position: 3, Value: "go"},
+		}},
+		{"negated word and phrase", `-rust -"hello world"`, []Term{
+			&Text{Position: 0, Value: "rust", Negated: true},
+			&Text{Position: 6, Value: "hello world", Phrase: true, Negated: true},
+		}},
+		{"lone dash is punctuation", "well - known", []Term{
+			&Text{Position: 0, Value: "well"},
+			&Text{Position: 7, Value: "known"},
+		}},
+		{"OR stays text for websearch_to_tsquery", "go OR rust", []Term{
+			&Text{Position: 0, Value: "go"},
+			&Text{Position: 3, Value: "OR"},
+			&Text{Position: 6, Value: "rust"},
+		}},
+		{"from strips @", "from:@golang", []Term{
+			&Filter{Position: 0, Key: KeyFrom, Value: "golang"},
+		}},
+		{"operator keys are case-insensitive", "FROM:golang", []Term{
+			&Filter{Position: 0, Key: KeyFrom, Value: "golang"},
+		}},
+		{"tag unquoted", "tag:go", []Term{
+			&Filter{Position: 0, Key: KeyTag, Value: "go"},
+		}},
+		{"tag quoted", `tag:"To read" later`, []Term{
+			&Filter{Position: 0, Key: KeyTag, Value: "To read"},
+			&Text{Position: 14, Value: "later"},
+		}},
+		{"negated tag", "-tag:done", []Term{
+			&Filter{Position: 0, Key: KeyTag, Value: "done", Negated: true},
+		}},
+		{"has", "has:Video has:gif", []Term{
+			&Filter{Position: 0, Key: KeyHas, Value: "video"},
+			&Filter{Position: 10, Key: KeyHas, Value: "gif"},
+		}},
+		{"is", "is:archived -is:reply", []Term{
+			&Filter{Position: 0, Key: KeyIs, Value: "archived"},
+			&Filter{Position: 12, Key: KeyIs, Value: "reply", Negated: true},
+		}},
+		{"dates", "after:2024-01-31 before:2024-03-01", []Term{
+			&Filter{Position: 0, Key: KeyAfter, Value: "2024-01-31", Date: date("2024-01-31")},
+			&Filter{Position: 17, Key: KeyBefore, Value: "2024-03-01", Date: date("2024-03-01")},
+		}},
+		{"counts", "min_faves:100 min_retweets:0 -min_views:5000", []Term{
+			&Filter{Position: 0, Key: KeyMinFaves, Value: "100", Number: 100},
+			&Filter{Position: 14, Key: KeyMinRetweets, Value: "0", Number: 0},
+			&Filter{Position: 29, Key: KeyMinViews, Value: "5000", Number: 5000, Negated: true},
+		}},
+		{"URL is text", "https://go.dev/blog", []Term{
+			&Text{Position: 0, Value: "https://go.dev/blog"},
+		}},
+		{"unknown key is text", "note:later", []Term{
+			&Text{Position: 0, Value: "note:later"},
+		}},
+		{"bare colon is text", "a : b", []Term{
+			&Text{Position: 0, Value: "a"},
+			&Text{Position: 2, Value: ":"},
+			&Text{Position: 4, Value: "b"},
+		}},
+		{"unicode positions are byte offsets", "café tag:übung", []Term{
+			&Text{Position: 0, Value: "café"},
+			&Filter{Position: 6, Key: KeyTag, Value: "übung"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(query.Terms, tt.want) {
+				t.Errorf("Parse(%q) =\n%s\nwant\n%s", tt.input, describe(query.Terms), describe(tt.want))
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+	}{
+		{`"unterminated`, 0},
+		{`go "unterminated`, 3},
+		{`tag:"unterminated`, 4},
+		{"tag:", 4},
+		{"go -from:", 9},
+		{"from:not-a-name", 5},
+		{"has:audio", 4},
+		{"is:starred", 3},
+		{"before:2024-13-01", 7},
+		{"x after:yesterday", 8},
+		{"min_faves:-1", 10},
+		{"-min_views:many", 11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var syntaxErr *Error
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse(%q) error = %v, want a syntax error", tt.input, err)
+			}
+			if syntaxErr.Pos != tt.pos {
+				t.Errorf("Parse(%q) error at position %d, want %d (%v)", tt.input, syntaxErr.Pos, tt.pos, err)
+			}
+		})
+	}
+}
+
+func TestFullText(t *testing.T) {
+	query, err := Parse(`go OR rust -java "type parameters" from:golang`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `go OR rust -java "type parameters"`
+	if got := query.FullText(); got != want {
+		t.Errorf("FullText() = %q, want %q", got, want)
+	}
+}
+
+// describe prints terms by value, since %v on a slice shows pointers
+func describe(terms []Term) string {
+	var s string
+	for _, term := range terms {
+		s += fmt.Sprintf("  %T %+v\n", term, term)
+	}
+	return s
+}
@@ -0,0 +1,760 @@
+package services
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/helioLJ/tweetvault/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Backup archives are ZIP files holding manifest.json, tags.json, a streamed
+// bookmarks.json array (each bookmark with its media records and tag links)
+// and one media/<id> entry per stored media file. Version 2 adds
+// saved_searches.json and lists.json, and version 3 aliases.json.
+// BackupVersion is bumped whenever that layout changes; older versions must
+// stay restorable.
+const (
+	BackupFormat  = "tweetvault-backup"
+	BackupVersion = 3
+)
+
+// backupBatchSize is how many bookmarks are loaded per query while backing up
+const backupBatchSize = 200
+
+// ErrInvalidBackup is returned when a restore is given something that isn't
+// a backup archive this version can read
+var ErrInvalidBackup = errors.New("invalid backup archive")
+
+// BackupManifest describes a backup archive
+type BackupManifest struct {
+	Format        string    `json:"format"`
+	Version       int       `json:"version"`
+	CreatedAt     time.Time `json:"created_at"`
+	Bookmarks     int       `json:"bookmarks"`
+	Tags          int       `json:"tags"`
+	Media         int       `json:"media"`
+	Aliases       int       `json:"aliases"`
+	SavedSearches int       `json:"saved_searches"`
+	Lists         int       `json:"lists"`
+}
+
+// backupBookmark is a bookmark with everything that belongs to it. The outer
+// fields shadow the embedded ones so media and tag links carry their state.
+type backupBookmark struct {
+	models.Bookmark
+	UpdatedAt time.Time       `json:"updated_at"`
+	Media     []backupMedia   `json:"media"`
+	Tags      []backupTagLink `json:"tags"`
+}
+
+// backupMedia is a media record; Blob names the archive entry holding its
+// file data, empty when none was stored
+type backupMedia struct {
+	models.Media
+	Blob string `json:"blob,omitempty"`
+}
+
+// backupTagLink is a BookmarkTag referencing its tag by name, so links
+// resolve to the matching tag of the instance being restored into
+type backupTagLink struct {
+	Name      string    `json:"name"`
+	Completed bool      `json:"completed"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// backupAlias is a TagAlias referencing its tag by name
+type backupAlias struct {
+	Name      string    `json:"name"`
+	Tag       string    `json:"tag"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// backupList is a list with its bookmarks in order
+type backupList struct {
+	Name      string                `json:"name"`
+	CreatedAt time.Time             `json:"created_at"`
+	UpdatedAt time.Time             `json:"updated_at"`
+	Bookmarks []models.ListBookmark `json:"bookmarks"`
+}
+
+// RestoreReport summarizes what a restore did
+type RestoreReport struct {
+	Version              int    `json:"version"`
+	Conflict             string `json:"conflict"`
+	Created              int    `json:"created"`
+	Overwritten          int    `json:"overwritten"`
+	Skipped              int    `json:"skipped"`
+	TagsCreated          int    `json:"tags_created"`
+	AliasesCreated       int    `json:"aliases_created"`
+	SavedSearchesCreated int    `json:"saved_searches_created"`
+	ListsCreated         int    `json:"lists_created"`
+	Media                int    `json:"media"`
+}
+
+type BackupService struct {
+	db *gorm.DB
+}
+
+func NewBackupService(db *gorm.DB) *BackupService {
+	return &BackupService{db: db}
+}
+
+// ParseConflictPolicy validates how a restore treats bookmarks that already
+// exist: skip keeps them as they are, overwrite replaces them with the backup
+func ParseConflictPolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return models.MergeSkip, nil
+	case models.MergeSkip, models.MergeOverwrite:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid conflict policy %q: must be %s or %s",
+			policy, models.MergeSkip, models.MergeOverwrite)
+	}
+}
+
+// Write streams a complete backup archive to w. Bookmarks are loaded in
+// batches and media files one at a time to keep memory flat.
+func (s *BackupService) Write(w io.Writer) error {
+	archive := zip.NewWriter(w)
+	manifest := BackupManifest{
+		Format:    BackupFormat,
+		Version:   BackupVersion,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	// Read everything from one snapshot so media entries match their records
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		snapshot := &BackupService{db: tx}
+
+		var tags []models.Tag
+		if err := tx.Order("id").Find(&tags).Error; err != nil {
+			return err
+		}
+		manifest.Tags = len(tags)
+		if err := writeJSONEntry(archive, "tags.json", tags); err != nil {
+			return err
+		}
+
+		aliases := []backupAlias{}
+		if err := tx.Table("tag_aliases").
+			Select("tag_aliases.name, tags.name AS tag, tag_aliases.created_at").
+			Joins("JOIN tags ON tags.id = tag_aliases.tag_id").
+			Order("tag_aliases.id").
+			Scan(&aliases).Error; err != nil {
+			return err
+		}
+		manifest.Aliases = len(aliases)
+		if err := writeJSONEntry(archive, "aliases.json", aliases); err != nil {
+			return err
+		}
+
+		searches := []models.SavedSearch{}
+		if err := tx.Order("id").Find(&searches).Error; err != nil {
+			return err
+		}
+		manifest.SavedSearches = len(searches)
+		if err := writeJSONEntry(archive, "saved_searches.json", searches); err != nil {
+			return err
+		}
+
+		lists, err := snapshot.loadBackupLists()
+		if err != nil {
+			return err
+		}
+		manifest.Lists = len(lists)
+		if err := writeJSONEntry(archive, "lists.json", lists); err != nil {
+			return err
+		}
+
+		count, err := snapshot.writeBookmarks(archive)
+		if err != nil {
+			return err
+		}
+		manifest.Bookmarks = count
+
+		manifest.Media, err = snapshot.writeMediaBlobs(archive)
+		return err
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+
+	if err := writeJSONEntry(archive, "manifest.json", manifest); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// loadBackupLists loads every list with its bookmarks by position
+func (s *BackupService) loadBackupLists() ([]backupList, error) {
+	var lists []models.List
+	if err := s.db.Order("id").Find(&lists).Error; err != nil {
+		return nil, err
+	}
+	var entries []models.ListBookmark
+	if err := s.db.Order("list_id, position").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	records := make([]backupList, len(lists))
+	index := make(map[uint]int, len(lists))
+	for i, list := range lists {
+		index[list.ID] = i
+		records[i] = backupList{
+			Name:      list.Name,
+			CreatedAt: list.CreatedAt,
+			UpdatedAt: list.UpdatedAt,
+			Bookmarks: []models.ListBookmark{},
+		}
+	}
+	for _, entry := range entries {
+		i := index[entry.ListID]
+		entry.ListID = 0 // Lists get new IDs on restore
+		records[i].Bookmarks = append(records[i].Bookmarks, entry)
+	}
+	return records, nil
+}
+
+func (s *BackupService) writeBookmarks(archive *zip.Writer) (int, error) {
+	entry, err := archive.Create("bookmarks.json")
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.WriteString(entry, "["); err != nil {
+		return 0, err
+	}
+
+	encoder := json.NewEncoder(entry)
+	count := 0
+	lastID := ""
+	for {
+		var bookmarks []models.Bookmark
+		if err := s.db.Where("id > ?", lastID).Order("id").Limit(backupBatchSize).Find(&bookmarks).Error; err != nil {
+			return 0, err
+		}
+		if len(bookmarks) == 0 {
+			break
+		}
+		lastID = bookmarks[len(bookmarks)-1].ID
+
+		records, err := s.loadBackupBookmarks(bookmarks)
+		if err != nil {
+			return 0, err
+		}
+		for _, record := range records {
+			if count > 0 {
+				if _, err := io.WriteString(entry, ","); err != nil {
+					return 0, err
+				}
+			}
+			if err := encoder.Encode(record); err != nil {
+				return 0, err
+			}
+			count++
+		}
+	}
+
+	_, err = io.WriteString(entry, "]")
+	return count, err
+}
+
+// loadBackupBookmarks attaches media records and tag links to a batch
+func (s *BackupService) loadBackupBookmarks(bookmarks []models.Bookmark) ([]backupBookmark, error) {
+	ids := make([]string, len(bookmarks))
+	records := make([]backupBookmark, len(bookmarks))
+	index := make(map[string]int, len(bookmarks))
+	for i, bookmark := range bookmarks {
+		ids[i] = bookmark.ID
+		index[bookmark.ID] = i
+		records[i] = backupBookmark{
+			Bookmark:  bookmark,
+			UpdatedAt: bookmark.UpdatedAt,
+			Media:     []backupMedia{},
+			Tags:      []backupTagLink{},
+		}
+	}
+
+	var media []struct {
+		models.Media
+		HasData bool
+	}
+	if err := s.db.Model(&models.Media{}).
+		Select("id, tweet_id, type, url, thumbnail, original, file_name, created_at, updated_at, file_data IS NOT NULL AS has_data").
+		Where("tweet_id IN ?", ids).
+		Order("id").
+		Find(&media).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range media {
+		record := backupMedia{Media: m.Media}
+		if m.HasData {
+			record.Blob = mediaBlobName(m.ID)
+		}
+		i := index[m.TweetID]
+		records[i].Media = append(records[i].Media, record)
+	}
+
+	var links []struct {
+		BookmarkID string
+		backupTagLink
+	}
+	if err := s.db.Table("bookmark_tags").
+		Select("bookmark_tags.bookmark_id, tags.name, bookmark_tags.completed, bookmark_tags.created_at").
+		Joins("JOIN tags ON tags.id = bookmark_tags.tag_id").
+		Where("bookmark_tags.bookmark_id IN ?", ids).
+		Order("tags.name").
+		Scan(&links).Error; err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		i := index[link.BookmarkID]
+		records[i].Tags = append(records[i].Tags, link.backupTagLink)
+	}
+
+	return records, nil
+}
+
+// writeMediaBlobs stores each media file uncompressed, since photos and
+// videos are already compressed
+func (s *BackupService) writeMediaBlobs(archive *zip.Writer) (int, error) {
+	count := 0
+	var lastID uint
+	for {
+		var ids []uint
+		if err := s.db.Model(&models.Media{}).
+			Where("id > ? AND file_data IS NOT NULL", lastID).
+			Order("id").
+			Limit(backupBatchSize).
+			Pluck("id", &ids).Error; err != nil {
+			return 0, err
+		}
+		if len(ids) == 0 {
+			return count, nil
+		}
+		lastID = ids[len(ids)-1]
+
+		for _, id := range ids {
+			var media models.Media
+			if err := s.db.Select("file_data").Where("id = ?", id).Take(&media).Error; err != nil {
+				return 0, err
+			}
+
+			entry, err := archive.CreateHeader(&zip.FileHeader{Name: mediaBlobName(id), Method: zip.Store})
+			if err != nil {
+				return 0, err
+			}
+			if _, err := entry.Write(media.FileData); err != nil {
+				return 0, err
+			}
+			count++
+		}
+	}
+}
+
+// Restore loads a backup archive in a single transaction, so a failed
+// restore leaves the instance untouched. conflict is models.MergeSkip or
+// models.MergeOverwrite.
+func (s *BackupService) Restore(path string, conflict string) (*RestoreReport, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	defer archive.Close()
+
+	entries := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		entries[f.Name] = f
+	}
+
+	var manifest BackupManifest
+	if err := readJSONEntry(entries, "manifest.json", &manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Format != BackupFormat {
+		return nil, fmt.Errorf("%w: not a %s archive", ErrInvalidBackup, BackupFormat)
+	}
+	if manifest.Version < 1 || manifest.Version > BackupVersion {
+		return nil, fmt.Errorf("%w: version %d is not supported (this server reads up to %d)",
+			ErrInvalidBackup, manifest.Version, BackupVersion)
+	}
+
+	var tags []models.Tag
+	if err := readJSONEntry(entries, "tags.json", &tags); err != nil {
+		return nil, err
+	}
+	var searches []models.SavedSearch
+	var lists []backupList
+	if manifest.Version >= 2 {
+		if err := readJSONEntry(entries, "saved_searches.json", &searches); err != nil {
+			return nil, err
+		}
+		if err := readJSONEntry(entries, "lists.json", &lists); err != nil {
+			return nil, err
+		}
+	}
+	var aliases []backupAlias
+	if manifest.Version >= 3 {
+		if err := readJSONEntry(entries, "aliases.json", &aliases); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &RestoreReport{Version: manifest.Version, Conflict: conflict}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		tagIDs, err := restoreTags(tx, tags, report)
+		if err != nil {
+			return err
+		}
+		if err := restoreAliases(tx, aliases, tagIDs, report); err != nil {
+			return err
+		}
+		if err := restoreSavedSearches(tx, searches, conflict, report); err != nil {
+			return err
+		}
+
+		file, ok := entries["bookmarks.json"]
+		if !ok {
+			return fmt.Errorf("%w: bookmarks.json is missing", ErrInvalidBackup)
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		decoder := json.NewDecoder(reader)
+		if _, err := decoder.Token(); err != nil {
+			return fmt.Errorf("%w: bookmarks.json: %v", ErrInvalidBackup, err)
+		}
+		for decoder.More() {
+			var record backupBookmark
+			if err := decoder.Decode(&record); err != nil {
+				return fmt.Errorf("%w: bookmarks.json: %v", ErrInvalidBackup, err)
+			}
+			if err := restoreBookmark(tx, &record, entries, tagIDs, conflict, report); err != nil {
+				return fmt.Errorf("bookmark %s: %w", record.ID, err)
+			}
+		}
+		return restoreLists(tx, lists, conflict, report)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := NewBookmarkService(s.db).RefreshView(); err != nil {
+		log.Printf("Error refreshing materialized view after restore: %v", err)
+	}
+	return report, nil
+}
+
+// restoreTags creates the tags missing from this instance and returns the
+// ID of every backed up tag by name. Created tags keep their backed up
+// parent; existing tags stay where they are.
+func restoreTags(tx *gorm.DB, tags []models.Tag, report *RestoreReport) (map[string]uint, error) {
+	// A backed up tag named like an alias here is restored into the alias's
+	// tag, so tag and alias names stay distinct
+	hierarchy, err := loadTagHierarchy(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	tagIDs := make(map[string]uint, len(tags))
+	backupNames := make(map[uint]string, len(tags))
+	var created []models.Tag
+	for _, tag := range tags {
+		backupNames[tag.ID] = tag.Name
+		var existing models.Tag
+		name, err := ValidateTagName(tag.Name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		metadata, err := ValidateTagMetadata(tag.TagMetadata)
+		if err != nil {
+			return nil, fmt.Errorf("%w: tag %q: %v", ErrInvalidBackup, name, err)
+		}
+		lookup := name
+		if stored, ok := hierarchy.resolve(name); ok {
+			lookup = stored
+		}
+		err = tx.Where("LOWER(name) = LOWER(?)", lookup).Take(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			existing = models.Tag{
+				Name:        name,
+				TagMetadata: metadata,
+				Completed:   tag.Completed,
+				CreatedAt:   tag.CreatedAt,
+				UpdatedAt:   tag.UpdatedAt,
+			}
+			if err := tx.Create(&existing).Error; err != nil {
+				return nil, err
+			}
+			report.TagsCreated++
+			created = append(created, tag)
+		} else if err != nil {
+			return nil, err
+		}
+		tagIDs[tag.Name] = existing.ID
+	}
+
+	for _, tag := range created {
+		if tag.ParentID == nil {
+			continue
+		}
+		parentID, ok := tagIDs[backupNames[*tag.ParentID]]
+		if !ok {
+			continue
+		}
+		if err := tx.Model(&models.Tag{}).Where("id = ?", tagIDs[tag.Name]).Update("parent_id", parentID).Error; err != nil {
+			return nil, err
+		}
+	}
+	return tagIDs, nil
+}
+
+// restoreAliases creates the aliases whose name is free on this instance,
+// pointing at the restored tag they named
+func restoreAliases(tx *gorm.DB, aliases []backupAlias, tagIDs map[string]uint, report *RestoreReport) error {
+	for _, alias := range aliases {
+		name, err := ValidateTagName(alias.Name)
+		if err != nil {
+			return fmt.Errorf("%w: alias: %v", ErrInvalidBackup, err)
+		}
+		tagID, ok := tagIDs[alias.Tag]
+		if !ok {
+			return fmt.Errorf("%w: alias %q: tag %q is not in tags.json", ErrInvalidBackup, name, alias.Tag)
+		}
+
+		var taken int64
+		if err := tx.Model(&models.Tag{}).Where("LOWER(name) = LOWER(?)", name).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken == 0 {
+			if err := tx.Model(&models.TagAlias{}).Where("LOWER(name) = LOWER(?)", name).Count(&taken).Error; err != nil {
+				return err
+			}
+		}
+		if taken > 0 {
+			continue
+		}
+
+		if err := tx.Create(&models.TagAlias{Name: name, TagID: tagID, CreatedAt: alias.CreatedAt}).Error; err != nil {
+			return err
+		}
+		report.AliasesCreated++
+	}
+	return nil
+}
+
+// restoreSavedSearches matches saved searches by name. Missing ones are
+// created; existing ones are replaced when overwriting.
+func restoreSavedSearches(tx *gorm.DB, searches []models.SavedSearch, conflict string, report *RestoreReport) error {
+	for _, search := range searches {
+		search.ID = 0
+		if search.Name == "" {
+			return fmt.Errorf("%w: saved search name is required", ErrInvalidBackup)
+		}
+		if _, _, err := SavedSearchQuery(&search); err != nil {
+			return fmt.Errorf("%w: saved search %q: %v", ErrInvalidBackup, search.Name, err)
+		}
+
+		var existing models.SavedSearch
+		err := tx.Select("id").Where("name = ?", search.Name).Take(&existing).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Create(&search).Error; err != nil {
+				return err
+			}
+			report.SavedSearchesCreated++
+		case err != nil:
+			return err
+		case conflict == models.MergeOverwrite:
+			if err := tx.Model(&existing).Select("*").Omit("id").Updates(&search).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// restoreLists matches lists by name, pairing backed up lists with existing
+// ones of the same name in ID order. Missing lists are created with their
+// bookmarks at the backed up positions; existing ones get the backed up
+// bookmarks and order when overwriting.
+func restoreLists(tx *gorm.DB, lists []backupList, conflict string, report *RestoreReport) error {
+	claimed := map[uint]bool{}
+	for _, record := range lists {
+		name, err := validateListName(record.Name)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+
+		var candidates []uint
+		if err := tx.Model(&models.List{}).Where("name = ?", name).Order("id").Pluck("id", &candidates).Error; err != nil {
+			return err
+		}
+		var listID uint
+		for _, id := range candidates {
+			if !claimed[id] {
+				listID = id
+				break
+			}
+		}
+
+		switch {
+		case listID == 0:
+			list := models.List{Name: name, CreatedAt: record.CreatedAt, UpdatedAt: record.UpdatedAt}
+			if err := tx.Create(&list).Error; err != nil {
+				return err
+			}
+			listID = list.ID
+			report.ListsCreated++
+		case conflict == models.MergeOverwrite:
+			if err := tx.Where("list_id = ?", listID).Delete(&models.ListBookmark{}).Error; err != nil {
+				return err
+			}
+		default:
+			claimed[listID] = true
+			continue
+		}
+		claimed[listID] = true
+
+		for _, entry := range record.Bookmarks {
+			entry.ListID = listID
+			if err := tx.Create(&entry).Error; err != nil {
+				return fmt.Errorf("list %q: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func restoreBookmark(tx *gorm.DB, record *backupBookmark, entries map[string]*zip.File, tagIDs map[string]uint, conflict string, report *RestoreReport) error {
+	bookmark := record.Bookmark
+	bookmark.UpdatedAt = record.UpdatedAt
+	bookmark.Media = nil
+	bookmark.Tags = nil
+	if string(bookmark.Metadata) == "null" {
+		bookmark.Metadata = nil // Keep SQL NULL rather than a JSON null
+	}
+
+	quiet := tx.Session(&gorm.Session{Logger: tx.Logger.LogMode(logger.Silent)})
+	var existing int64
+	if err := quiet.Model(&models.Bookmark{}).Where("id = ?", bookmark.ID).Count(&existing).Error; err != nil {
+		return err
+	}
+
+	switch {
+	case existing == 0:
+		if err := tx.Create(&bookmark).Error; err != nil {
+			return err
+		}
+		report.Created++
+	case conflict == models.MergeOverwrite:
+		// Select("*") writes zero values too, including archived = false
+		if err := tx.Model(&models.Bookmark{}).Where("id = ?", bookmark.ID).
+			Select("*").Omit("id").Updates(&bookmark).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("bookmark_id = ?", bookmark.ID).Delete(&models.BookmarkTag{}).Error; err != nil {
+			return err
+		}
+		report.Overwritten++
+	default:
+		report.Skipped++
+		return nil
+	}
+
+	for _, link := range record.Tags {
+		tagID, ok := tagIDs[link.Name]
+		if !ok {
+			return fmt.Errorf("%w: tag %q is not in tags.json", ErrInvalidBackup, link.Name)
+		}
+		if err := tx.Create(&models.BookmarkTag{
+			BookmarkID: bookmark.ID,
+			TagID:      tagID,
+			Completed:  link.Completed,
+			CreatedAt:  link.CreatedAt,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, m := range record.Media {
+		if err := restoreMedia(tx, bookmark.ID, &m, entries); err != nil {
+			return err
+		}
+		report.Media++
+	}
+	return nil
+}
+
+// restoreMedia matches media by tweet and file name like imports do; the
+// backed up ID is not reused since it may belong to another row here
+func restoreMedia(tx *gorm.DB, tweetID string, record *backupMedia, entries map[string]*zip.File) error {
+	media := record.Media
+	media.ID = 0
+	media.TweetID = tweetID
+
+	if record.Blob != "" {
+		data, err := readEntry(entries, record.Blob)
+		if err != nil {
+			return err
+		}
+		media.FileData = data
+	}
+
+	var existing models.Media
+	err := tx.Select("id").Where("tweet_id = ? AND file_name = ?", tweetID, media.FileName).Take(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return tx.Create(&media).Error
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Model(&existing).Select("*").Omit("id").Updates(&media).Error
+}
+
+func mediaBlobName(id uint) string {
+	return fmt.Sprintf("media/%d", id)
+}
+
+func writeJSONEntry(archive *zip.Writer, name string, v interface{}) error {
+	entry, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(entry).Encode(v)
+}
+
+func readEntry(entries map[string]*zip.File, name string) ([]byte, error) {
+	file, ok := entries[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is missing", ErrInvalidBackup, name)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func readJSONEntry(entries map[string]*zip.File, name string, v interface{}) error {
+	data, err := readEntry(entries, name)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidBackup, name, err)
+	}
+	return nil
+}
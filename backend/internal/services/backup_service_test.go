@@ -0,0 +1,280 @@
+package services
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/helioLJ/tweetvault/internal/models"
+	"gorm.io/gorm"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	source := testDB(t)
+	seedLibrary(t, source)
+
+	var archive bytes.Buffer
+	if err := NewBackupService(source).Write(&archive); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "backup.zip")
+	if err := os.WriteFile(path, archive.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	target := testDB(t)
+	report, err := NewBackupService(target).Restore(path, models.MergeSkip)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	want := RestoreReport{
+		Version:              BackupVersion,
+		Conflict:             models.MergeSkip,
+		Created:              2,
+		TagsCreated:          2,
+		AliasesCreated:       1,
+		SavedSearchesCreated: 1,
+		ListsCreated:         1,
+		Media:                2,
+	}
+	if *report != want {
+		t.Errorf("report = %+v, want %+v", *report, want)
+	}
+
+	restored, original := loadLibrary(t, target), loadLibrary(t, source)
+	if !reflect.DeepEqual(restored, original) {
+		t.Errorf("restored library differs\n got: %+v\nwant: %+v", restored, original)
+	}
+}
+
+func TestRestoreTagNamedLikeAnAlias(t *testing.T) {
+	source := testDB(t)
+	seedLibrary(t, source)
+	var archive bytes.Buffer
+	if err := NewBackupService(source).Write(&archive); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "backup.zip")
+	if err := os.WriteFile(path, archive.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Here "papers" is an alias of Studies, so the backed up Papers tag
+	// must be restored into Studies
+	target := testDB(t)
+	studies := models.Tag{Name: "Studies"}
+	mustCreate(t, target, &studies)
+	mustCreate(t, target, &models.TagAlias{Name: "papers", TagID: studies.ID})
+
+	if _, err := NewBackupService(target).Restore(path, models.MergeSkip); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	var count int64
+	if err := target.Model(&models.Tag{}).Where("LOWER(name) = 'papers'").Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("restore created a tag named like the alias papers")
+	}
+	var links int64
+	if err := target.Model(&models.BookmarkTag{}).Where("tag_id = ?", studies.ID).Count(&links).Error; err != nil {
+		t.Fatal(err)
+	}
+	if links != 2 {
+		t.Errorf("Studies has %d bookmarks, want the 2 tagged Papers", links)
+	}
+}
+
+// seedLibrary fills a database with an active and an archived bookmark,
+// media with and without stored files, nested tags with completion flags,
+// metadata and an alias, a saved search and a list
+func seedLibrary(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	position := 1
+	parent := models.Tag{Name: "Reading", TagMetadata: models.TagMetadata{Color: "#336699", Icon: "📚", Position: &position}}
+	mustCreate(t, db, &parent)
+	child := models.Tag{Name: "Papers", ParentID: &parent.ID, TagMetadata: models.TagMetadata{Description: "Long reads"}}
+	mustCreate(t, db, &child)
+
+	created := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	mustCreate(t, db, &models.Bookmark{
+		ID:         "1764000000000000001",
+		CreatedAt:  created,
+		FullText:   "A thread worth keeping #go",
+		ScreenName: "gopher",
+		Name:       "Gopher",
+		URL:        "https://x.com/gopher/status/1764000000000000001",
+	})
+	mustCreate(t, db, &models.Bookmark{
+		ID:         "1764000000000000002",
+		CreatedAt:  created.Add(time.Hour),
+		FullText:   "Read later",
+		ScreenName: "writer",
+		Archived:   true,
+	})
+
+	mustCreate(t, db, &models.Media{
+		TweetID:  "1764000000000000001",
+		Type:     "photo",
+		FileName: "photo.jpg",
+		FileData: []byte("\xff\xd8\xff\xe0 not really a jpeg"),
+	})
+	mustCreate(t, db, &models.Media{
+		TweetID:   "1764000000000000001",
+		Type:      "video",
+		FileName:  "video.mp4",
+		Thumbnail: "https://pbs.twimg.com/thumb.jpg",
+	})
+
+	mustCreate(t, db, &models.BookmarkTag{BookmarkID: "1764000000000000001", TagID: parent.ID, Completed: true, CreatedAt: created})
+	mustCreate(t, db, &models.BookmarkTag{BookmarkID: "1764000000000000001", TagID: child.ID, CreatedAt: created})
+	mustCreate(t, db, &models.BookmarkTag{BookmarkID: "1764000000000000002", TagID: child.ID, Completed: true, CreatedAt: created})
+
+	mustCreate(t, db, &models.TagAlias{Name: "Research", TagID: child.ID})
+	mustCreate(t, db, &models.SavedSearch{
+		Name:     "Unread papers",
+		Search:   "from:gopher -is:reply",
+		Tags:     models.StringList{"Papers"},
+		TagMode:  "all",
+		Archived: true,
+		Sort:     SortDate,
+		Order:    "asc",
+	})
+
+	// Newest first, so positions differ from ID order
+	list := models.List{Name: "Weekend"}
+	mustCreate(t, db, &list)
+	mustCreate(t, db, &models.ListBookmark{ListID: list.ID, BookmarkID: "1764000000000000002", Position: 0})
+	mustCreate(t, db, &models.ListBookmark{ListID: list.ID, BookmarkID: "1764000000000000001", Position: 1})
+}
+
+func mustCreate(t *testing.T, db *gorm.DB, value interface{}) {
+	t.Helper()
+	if err := db.Create(value).Error; err != nil {
+		t.Fatalf("creating %T: %v", value, err)
+	}
+}
+
+// library is the content of a database in a form that doesn't depend on
+// generated IDs, for comparing a restore with its source
+type library struct {
+	Bookmarks     map[string]libraryBookmark
+	Tags          map[string]libraryTag
+	Aliases       map[string]string             // Alias name to tag name
+	SavedSearches map[string]models.SavedSearch // By name, without ID and times
+	Lists         map[string][]string           // List name to bookmark IDs by position
+}
+
+type libraryBookmark struct {
+	CreatedAt  time.Time
+	FullText   string
+	ScreenName string
+	Name       string
+	URL        string
+	Archived   bool
+	Media      map[string]libraryMedia // By file name
+	Tags       map[string]bool         // Tag name to completed
+}
+
+type libraryMedia struct {
+	Type      string
+	Thumbnail string
+	FileData  []byte
+}
+
+type libraryTag struct {
+	Parent   string
+	Metadata models.TagMetadata
+}
+
+func loadLibrary(t *testing.T, db *gorm.DB) library {
+	t.Helper()
+	lib := library{
+		Bookmarks:     map[string]libraryBookmark{},
+		Tags:          map[string]libraryTag{},
+		Aliases:       map[string]string{},
+		SavedSearches: map[string]models.SavedSearch{},
+		Lists:         map[string][]string{},
+	}
+
+	var tags []models.Tag
+	if err := db.Find(&tags).Error; err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[uint]string, len(tags))
+	for _, tag := range tags {
+		names[tag.ID] = tag.Name
+	}
+	for _, tag := range tags {
+		var parent string
+		if tag.ParentID != nil {
+			parent = names[*tag.ParentID]
+		}
+		lib.Tags[tag.Name] = libraryTag{Parent: parent, Metadata: tag.TagMetadata}
+	}
+
+	var bookmarks []models.Bookmark
+	if err := db.Preload("Media").Find(&bookmarks).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range bookmarks {
+		record := libraryBookmark{
+			CreatedAt:  b.CreatedAt.UTC(),
+			FullText:   b.FullText,
+			ScreenName: b.ScreenName,
+			Name:       b.Name,
+			URL:        b.URL,
+			Archived:   b.Archived,
+			Media:      map[string]libraryMedia{},
+			Tags:       map[string]bool{},
+		}
+		for _, m := range b.Media {
+			record.Media[m.FileName] = libraryMedia{Type: m.Type, Thumbnail: m.Thumbnail, FileData: m.FileData}
+		}
+		lib.Bookmarks[b.ID] = record
+	}
+
+	var links []models.BookmarkTag
+	if err := db.Find(&links).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range links {
+		lib.Bookmarks[link.BookmarkID].Tags[names[link.TagID]] = link.Completed
+	}
+
+	var aliases []models.TagAlias
+	if err := db.Find(&aliases).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, alias := range aliases {
+		lib.Aliases[alias.Name] = names[alias.TagID]
+	}
+
+	var searches []models.SavedSearch
+	if err := db.Find(&searches).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, search := range searches {
+		search.ID, search.CreatedAt, search.UpdatedAt = 0, time.Time{}, time.Time{}
+		lib.SavedSearches[search.Name] = search
+	}
+
+	var lists []models.List
+	if err := db.Find(&lists).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, list := range lists {
+		var ids []string
+		if err := db.Model(&models.ListBookmark{}).Where("list_id = ?", list.ID).
+			Order("position").Pluck("bookmark_id", &ids).Error; err != nil {
+			t.Fatal(err)
+		}
+		lib.Lists[list.Name] = ids
+	}
+	return lib
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helioLJ/tweetvault/internal/models"
+	"gorm.io/gorm"
+)
+
+// Page size bounds for the bookmark list
+const (
+	DefaultListLimit = 12
+	MaxListLimit     = 100
+)
+
+// ErrInvalidCursor is returned for cursors that can't be decoded or were
+// issued for a different sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListPage selects a page of the bookmark list, either by number with OFFSET
+// or relative to a cursor. After and Before are mutually exclusive.
+type ListPage struct {
+	Number int
+	Limit  int
+	After  string
+	Before string
+}
+
+// ListResult is a page of the bookmark list. The cursors point past the
+// last and before the first bookmark, and are empty at either end.
+type ListResult struct {
+	Bookmarks  []models.BookmarkView
+	Total      int64
+	NextCursor string
+	PrevCursor string
+}
+
+// ParseListPage validates the page, limit, after and before parameters
+func ParseListPage(page, limit, after, before string) (ListPage, error) {
+	result := ListPage{Number: 1, Limit: DefaultListLimit, After: after, Before: before}
+
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxListLimit {
+			return ListPage{}, fmt.Errorf("invalid limit %q: must be between 1 and %d", limit, MaxListLimit)
+		}
+		result.Limit = n
+	}
+
+	if after != "" && before != "" {
+		return ListPage{}, errors.New("after and before can't be combined")
+	}
+	if page != "" {
+		if after != "" || before != "" {
+			return ListPage{}, errors.New("page can't be combined with a cursor")
+		}
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return ListPage{}, fmt.Errorf("invalid page %q: must be a positive number", page)
+		}
+		result.Number = n
+	}
+
+	return result, nil
+}
+
+// listCursor is the position of a bookmark in a sort order. It is encoded
+// as base64 JSON so clients treat it as opaque.
+type listCursor struct {
+	Sort      string     `json:"s"`
+	Ascending bool       `json:"a,omitempty"`
+	ID        string     `json:"id"`
+	Time      *time.Time `json:"t,omitempty"` // Date sorts; the tweet date for relevance
+	Number    int        `json:"n,omitempty"` // Count sorts
+	Text      string     `json:"x,omitempty"` // Author sort
+	Rank      float32    `json:"r,omitempty"` // Relevance sort
+}
+
+func encodeCursor(sort BookmarkSort, bookmark *models.BookmarkView) string {
+	cursor := listCursor{Sort: sort.Key, Ascending: sort.Ascending, ID: bookmark.ID}
+	switch sort.Key {
+	case SortImported:
+		cursor.Time = &bookmark.ImportedAt
+	case SortTagged:
+		cursor.Time = bookmark.TaggedAt
+	case SortFavorites:
+		cursor.Number = bookmark.FavoriteCount
+	case SortRetweets:
+		cursor.Number = bookmark.RetweetCount
+	case SortViews:
+		cursor.Number = bookmark.ViewsCount
+	case SortAuthor:
+		cursor.Text = strings.ToLower(bookmark.ScreenName)
+	case SortRelevance:
+		cursor.Rank = bookmark.Rank
+		cursor.Time = &bookmark.CreatedAt
+	default:
+		cursor.Time = &bookmark.CreatedAt
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string, sort BookmarkSort) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor listCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != sort.Key || cursor.Ascending != sort.Ascending {
+		return nil, fmt.Errorf("%w: it was issued for a different sort", ErrInvalidCursor)
+	}
+	return &cursor, nil
+}
+
+// applyCursor keeps the rows after (forward) or before the cursor in the
+// display order of the sort
+func applyCursor(query *gorm.DB, sort BookmarkSort, cursor *listCursor, search textSearch, forward bool) *gorm.DB {
+	// Descending lists continue with smaller values, ascending ones with larger
+	op := ">"
+	if sort.Ascending != forward {
+		op = "<"
+	}
+
+	switch sort.Key {
+	case SortRelevance:
+		rank, args := search.rank()
+		return query.Where(fmt.Sprintf("(%s, created_at, id) %s (?, ?, ?)", rank, op),
+			append(args, cursor.Rank, cursor.Time, cursor.ID)...)
+	case SortTagged:
+		// Never-tagged bookmarks come last in both directions
+		switch {
+		case cursor.Time != nil && forward:
+			return query.Where(fmt.Sprintf("(tagged_at %[1]s ? OR (tagged_at = ? AND id %[1]s ?) OR tagged_at IS NULL)", op),
+				cursor.Time, cursor.Time, cursor.ID)
+		case cursor.Time != nil:
+			return query.Where(fmt.Sprintf("(tagged_at %[1]s ? OR (tagged_at = ? AND id %[1]s ?))", op),
+				cursor.Time, cursor.Time, cursor.ID)
+		case forward:
+			return query.Where(fmt.Sprintf("tagged_at IS NULL AND id %s ?", op), cursor.ID)
+		default:
+			return query.Where(fmt.Sprintf("(tagged_at IS NOT NULL OR id %s ?)", op), cursor.ID)
+		}
+	}
+
+	var value interface{}
+	switch sort.Key {
+	case SortFavorites, SortRetweets, SortViews:
+		value = cursor.Number
+	case SortAuthor:
+		value = cursor.Text
+	default:
+		value = cursor.Time
+	}
+	return query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", sortColumns[sort.Key].expression, op), value, cursor.ID)
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/helioLJ/tweetvault/internal/importers"
+	"github.com/helioLJ/tweetvault/internal/models"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is how many bookmarks are loaded per query while exporting
+const exportBatchSize = 500
+
+// csvExportHeader uses the CSV importer's canonical column names, so an
+// export can be uploaded again as is
+var csvExportHeader = []string{
+	"id", "created_at", "full_text", "screen_name", "name", "profile_image_url",
+	"in_reply_to", "retweeted_status", "quoted_status",
+	"favorite_count", "retweet_count", "bookmark_count", "quote_count", "reply_count", "views_count",
+	"favorited", "retweeted", "bookmarked", "url", "archived", "media", "tags",
+}
+
+// exportMedia is a media entry in the format twitter-web-exporter uses
+type exportMedia struct {
+	Type      string `json:"type"`
+	URL       string `json:"url"`
+	Thumbnail string `json:"thumbnail"`
+	Original  string `json:"original"`
+}
+
+// ExportCSV writes the bookmarks matching a list filter as CSV, newest
+// first. Rows are written in batches so large libraries stream to the client.
+func (s *BookmarkService) ExportCSV(w io.Writer, filter BookmarkFilter) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvExportHeader); err != nil {
+		return err
+	}
+
+	for offset := 0; ; offset += exportBatchSize {
+		query, _, err := s.filteredView(filter)
+		if err != nil {
+			return err
+		}
+
+		var ids []string
+		if err := query.
+			Order("created_at DESC, id").
+			Offset(offset).
+			Limit(exportBatchSize).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		var bookmarks []models.Bookmark
+		if err := s.db.
+			Preload("Media", func(db *gorm.DB) *gorm.DB {
+				return db.Select("id", "tweet_id", "type", "url", "thumbnail", "original").Order("id") // Exclude heavy fields
+			}).
+			Preload("Tags", func(db *gorm.DB) *gorm.DB {
+				return db.Order("tags.name")
+			}).
+			Where("id IN ?", ids).
+			Find(&bookmarks).Error; err != nil {
+			return err
+		}
+
+		// Write rows in the view's order
+		byID := make(map[string]*models.Bookmark, len(bookmarks))
+		for i := range bookmarks {
+			byID[bookmarks[i].ID] = &bookmarks[i]
+		}
+		for _, id := range ids {
+			if bookmark, ok := byID[id]; ok {
+				if err := writer.Write(bookmarkCSVRecord(bookmark)); err != nil {
+					return err
+				}
+			}
+		}
+
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+		if len(ids) < exportBatchSize {
+			break
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func bookmarkCSVRecord(b *models.Bookmark) []string {
+	var media string
+	if len(b.Media) > 0 {
+		entries := make([]exportMedia, len(b.Media))
+		for i, m := range b.Media {
+			entries[i] = exportMedia{Type: m.Type, URL: m.URL, Thumbnail: m.Thumbnail, Original: m.Original}
+		}
+		data, _ := json.Marshal(entries)
+		media = string(data)
+	}
+
+	tags := make([]string, len(b.Tags))
+	quoteTags := false
+	for i, tag := range b.Tags {
+		tags[i] = tag.Name
+		quoteTags = quoteTags || strings.ContainsAny(tag.Name, importers.TagSeparators)
+	}
+	tagCell := strings.Join(tags, ", ")
+	if quoteTags {
+		// A separator inside a name would split it on import
+		data, _ := json.Marshal(tags)
+		tagCell = string(data)
+	}
+
+	return []string{
+		b.ID,
+		b.CreatedAt.Format("2006-01-02 15:04:05 -0700"),
+		b.FullText,
+		b.ScreenName,
+		b.Name,
+		b.ProfileImageURL,
+		b.InReplyTo.String,
+		b.RetweetedStatus.String,
+		b.QuotedStatus.String,
+		strconv.Itoa(b.FavoriteCount),
+		strconv.Itoa(b.RetweetCount),
+		strconv.Itoa(b.BookmarkCount),
+		strconv.Itoa(b.QuoteCount),
+		strconv.Itoa(b.ReplyCount),
+		strconv.Itoa(b.ViewsCount),
+		strconv.FormatBool(b.Favorited),
+		strconv.FormatBool(b.Retweeted),
+		strconv.FormatBool(b.Bookmarked),
+		b.URL,
+		strconv.FormatBool(b.Archived),
+		media,
+		tagCell,
+	}
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Facet names accepted by ParseFacets
+const (
+	FacetTags     = "tags"
+	FacetAuthors  = "authors"
+	FacetMedia    = "media"
+	FacetDates    = "dates"
+	FacetArchived = "archived"
+)
+
+var facetNames = []string{FacetTags, FacetAuthors, FacetMedia, FacetDates, FacetArchived}
+
+// maxFacetValues caps the tag and author facets to their most common values
+const maxFacetValues = 50
+
+// FacetCount is the number of matching bookmarks for one facet value
+type FacetCount struct {
+	Value     string `json:"value"`
+	Count     int64  `json:"count"`
+	Completed int64  `json:"completed,omitempty"` // Tags only: bookmarks where the tag is completed
+}
+
+// Facets break down the bookmarks matching a filter. Facets that weren't
+// requested are nil.
+type Facets struct {
+	Tags     []FacetCount    `json:"tags"`
+	Authors  []FacetCount    `json:"authors"`
+	Media    []FacetCount    `json:"media"`
+	Years    []FacetCount    `json:"years"`
+	Months   []FacetCount    `json:"months"` // YYYY-MM, newest first
+	Archived *ArchivedCounts `json:"archived"`
+}
+
+// ArchivedCounts splits the matches of a filter, ignoring its archived flag
+type ArchivedCounts struct {
+	Active   int64 `json:"active"`
+	Archived int64 `json:"archived"`
+}
+
+// ParseFacets reads a facets parameter: "true" or "all" for every facet, or
+// a comma-separated list of names
+func ParseFacets(value string) ([]string, error) {
+	switch value {
+	case "", "false":
+		return nil, nil
+	case "true", "all":
+		return facetNames, nil
+	}
+
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if !isFacetName(name) {
+			return nil, fmt.Errorf("invalid facet %q: must be one of %s", name, strings.Join(facetNames, ", "))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func isFacetName(name string) bool {
+	for _, known := range facetNames {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Facets counts the bookmarks matching a filter by the requested facets
+func (s *BookmarkService) Facets(filter BookmarkFilter, names []string) (*Facets, error) {
+	query, _, err := s.filteredView(filter)
+	if err != nil {
+		return nil, err
+	}
+	matches := query.Session(&gorm.Session{})
+
+	facets := &Facets{}
+	for _, name := range names {
+		// Requested facets are empty lists rather than null when nothing matches
+		var counts []FacetCount
+		switch name {
+		case FacetTags:
+			err = s.db.Table("(?) AS f, json_array_elements(f.tags_json) AS t", matches.Select("id, tags_json")).
+				Select("t->>'name' AS value, COUNT(*) AS count, COUNT(*) FILTER (WHERE (t->>'completed')::boolean) AS completed").
+				Group("value").
+				Order("count DESC, value").
+				Limit(maxFacetValues).
+				Scan(&counts).Error
+			facets.Tags = nonNil(counts)
+		case FacetAuthors:
+			err = matches.
+				Select("screen_name AS value, COUNT(*) AS count").
+				Group("screen_name").
+				Order("count DESC, value").
+				Limit(maxFacetValues).
+				Scan(&counts).Error
+			facets.Authors = nonNil(counts)
+		case FacetMedia:
+			// A bookmark with two photos counts once
+			err = s.db.Table("(?) AS f, json_array_elements(f.media_json) AS m", matches.Select("id, media_json")).
+				Select("m->>'type' AS value, COUNT(DISTINCT f.id) AS count").
+				Group("value").
+				Order("count DESC, value").
+				Scan(&counts).Error
+			facets.Media = nonNil(counts)
+		case FacetDates:
+			err = matches.
+				Select("to_char(created_at, 'YYYY-MM') AS value, COUNT(*) AS count").
+				Group("value").
+				Order("value DESC").
+				Scan(&counts).Error
+			facets.Months = nonNil(counts)
+			facets.Years = nonNil(yearCounts(counts))
+		case FacetArchived:
+			facets.Archived, err = s.archivedCounts(filter)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to count %s facet: %w", name, err)
+		}
+	}
+
+	return facets, nil
+}
+
+func nonNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
+
+// yearCounts rolls month counts, newest first, up into years
+func yearCounts(months []FacetCount) []FacetCount {
+	var years []FacetCount
+	for _, month := range months {
+		year := month.Value[:4]
+		if len(years) == 0 || years[len(years)-1].Value != year {
+			years = append(years, FacetCount{Value: year})
+		}
+		years[len(years)-1].Count += month.Count
+	}
+	return years
+}
+
+func (s *BookmarkService) archivedCounts(filter BookmarkFilter) (*ArchivedCounts, error) {
+	counts := &ArchivedCounts{}
+	for _, archived := range []bool{false, true} {
+		filter.Archived = archived
+		query, _, err := s.filteredView(filter)
+		if err != nil {
+			return nil, err
+		}
+
+		count := &counts.Active
+		if archived {
+			count = &counts.Archived
+		}
+		if err := query.Count(count).Error; err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
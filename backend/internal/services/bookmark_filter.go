@@ -0,0 +1,111 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Tag match modes for BookmarkFilter.TagMode
+const (
+	TagModeAll = "all"
+	TagModeAny = "any"
+)
+
+// BookmarkFilter selects the bookmarks shown by the list, export and the
+// other views built on the same query
+type BookmarkFilter struct {
+	Search      string         // searchquery syntax
+	Fuzzy       bool           // Match the search text by trigram similarity
+	Archived    bool           // Show archived instead of active bookmarks
+	Tags        []TagCondition // Required tags, combined according to TagMode
+	TagMode     string         // TagModeAll (default) or TagModeAny
+	ExcludeTags []TagCondition // Bookmarks matching any of these are left out
+	Untagged    bool           // Only bookmarks without any tag
+}
+
+// TagCondition matches a tag by name, optionally in one completion state
+type TagCondition struct {
+	Name      string `json:"name"`
+	Completed *bool  `json:"completed,omitempty"`
+}
+
+// ParseTagCondition reads "name", "name:completed" or "name:pending". Tag
+// names can't contain ':', so the suffix is never part of the name.
+func ParseTagCondition(value string) TagCondition {
+	if name, found := strings.CutSuffix(value, ":completed"); found && name != "" {
+		completed := true
+		return TagCondition{Name: name, Completed: &completed}
+	}
+	if name, found := strings.CutSuffix(value, ":pending"); found && name != "" {
+		completed := false
+		return TagCondition{Name: name, Completed: &completed}
+	}
+	return TagCondition{Name: value}
+}
+
+// ParseTagMode validates a tag match mode, defaulting to all
+func ParseTagMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return TagModeAll, nil
+	case TagModeAll, TagModeAny:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid tag_mode %q: must be %s or %s", mode, TagModeAll, TagModeAny)
+	}
+}
+
+// applyTagFilter adds the tag conditions of a filter to a view query. A tag
+// also matches bookmarks with any of its descendant tags.
+func applyTagFilter(query *gorm.DB, filter BookmarkFilter, hierarchy *tagHierarchy) *gorm.DB {
+	if len(filter.Tags) > 0 {
+		if filter.TagMode == TagModeAny {
+			conditions := make([]string, len(filter.Tags))
+			var args []interface{}
+			for i, tag := range filter.Tags {
+				condition, tagArgs := tagMatch(tag, hierarchy)
+				conditions[i] = condition
+				args = append(args, tagArgs...)
+			}
+			query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+		} else {
+			for _, tag := range filter.Tags {
+				condition, args := tagMatch(tag, hierarchy)
+				query = query.Where(condition, args...)
+			}
+		}
+	}
+
+	for _, tag := range filter.ExcludeTags {
+		condition, args := tagMatch(tag, hierarchy)
+		query = query.Where("NOT "+condition, args...)
+	}
+
+	if filter.Untagged {
+		query = query.Where("json_array_length(tags_json) = 0")
+	}
+
+	return query
+}
+
+// tagMatch builds the condition matching a tag or any of its descendants,
+// in the completion state of the condition
+func tagMatch(tag TagCondition, hierarchy *tagHierarchy) (string, []interface{}) {
+	names := hierarchy.expand(tag.Name)
+	conditions := make([]string, len(names))
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		conditions[i] = "tags_json::jsonb @> ?"
+		args[i] = tagContains(TagCondition{Name: name, Completed: tag.Completed})
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// tagContains builds the JSON array that matches a tag in tags_json with @>
+func tagContains(tag TagCondition) string {
+	data, _ := json.Marshal([]TagCondition{tag})
+	return string(data)
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseTagCondition(t *testing.T) {
+	completed, pending := true, false
+	tests := []struct {
+		value string
+		want  TagCondition
+	}{
+		{"To read", TagCondition{Name: "To read"}},
+		{"To read:completed", TagCondition{Name: "To read", Completed: &completed}},
+		{"To read:pending", TagCondition{Name: "To read", Completed: &pending}},
+		{":completed", TagCondition{Name: ":completed"}},
+		{"To read:done", TagCondition{Name: "To read:done"}},
+	}
+	for _, tt := range tests {
+		if got := ParseTagCondition(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTagCondition(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+
+	// The suffixes can't be part of a name, since names can't contain ':'
+	if _, err := ValidateTagName("Read:pending"); !errors.Is(err, ErrInvalidTagName) {
+		t.Errorf("ValidateTagName(%q) error = %v, want ErrInvalidTagName", "Read:pending", err)
+	}
+}
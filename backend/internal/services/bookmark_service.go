@@ -2,14 +2,20 @@ package services
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"strconv"
+	"strings"
 
 	"github.com/helioLJ/tweetvault/internal/models"
+	"github.com/helioLJ/tweetvault/internal/searchquery"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// searchHeadlineOptions configures the ts_headline snippets returned with search results
+const searchHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2"
+
 type BookmarkService struct {
 	db *gorm.DB
 }
@@ -109,52 +115,117 @@ func (s *BookmarkService) Get(id string) (*models.Bookmark, error) {
 	return &bookmark, nil
 }
 
+// UpdateTags replaces a bookmark's tags, preserving the completion status and
+// tagged time of tags it keeps. Names are normalized and matched ignoring
+// case, and aliases of merged tags resolve to the tag they were merged into.
+// Invalid names return ErrInvalidTagName. Called on a transaction it runs
+// under a savepoint.
 func (s *BookmarkService) UpdateTags(id string, tags []string) error {
-	tx := s.db.Begin()
-
-	// Instead of deleting all tags, get existing ones first
-	var existingBookmarkTags []models.BookmarkTag
-	if err := tx.Where("bookmark_id = ?", id).Find(&existingBookmarkTags).Error; err != nil {
-		tx.Rollback()
+	tags, err := normalizeTagNames(tags)
+	if err != nil {
 		return err
 	}
 
-	// Create a map of existing tag completion status
-	existingCompletionStatus := make(map[string]bool)
-	for _, bt := range existingBookmarkTags {
-		var tag models.Tag
-		if err := tx.First(&tag, bt.TagID).Error; err != nil {
-			continue
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		tags, err := resolveTagAliases(tx, tags)
+		if err != nil {
+			return err
 		}
-		existingCompletionStatus[tag.Name] = bt.Completed
-	}
 
-	// Now delete existing tags
-	if err := tx.Where("bookmark_id = ?", id).Delete(&models.BookmarkTag{}).Error; err != nil {
-		tx.Rollback()
-		return err
+		// Keep the links that stay, so their completion state and tagged
+		// time survive, and only add and remove what changed
+		var existing []models.BookmarkTag
+		if err := tx.Where("bookmark_id = ?", id).Find(&existing).Error; err != nil {
+			return err
+		}
+		linked := make(map[uint]bool, len(existing))
+		for _, bt := range existing {
+			linked[bt.TagID] = true
+		}
+
+		keep := make(map[uint]bool, len(tags))
+		for _, tagName := range tags {
+			tag, err := findOrCreateTag(tx, tagName)
+			if err != nil {
+				return err
+			}
+			keep[tag.ID] = true
+			if linked[tag.ID] {
+				continue
+			}
+			if err := tx.Create(&models.BookmarkTag{BookmarkID: id, TagID: tag.ID}).Error; err != nil {
+				return err
+			}
+		}
+
+		var removed []uint
+		for _, bt := range existing {
+			if !keep[bt.TagID] {
+				removed = append(removed, bt.TagID)
+			}
+		}
+		if len(removed) > 0 {
+			if err := tx.Where("bookmark_id = ? AND tag_id IN ?", id, removed).Delete(&models.BookmarkTag{}).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// findOrCreateTag returns the tag with a normalized name, ignoring case, and
+// creates it when there is none
+func findOrCreateTag(tx *gorm.DB, name string) (*models.Tag, error) {
+	var tag models.Tag
+	err := tx.Where("LOWER(name) = LOWER(?)", name).Take(&tag).Error
+	if err == nil {
+		return &tag, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
 	}
 
-	// Add all tags, preserving completion status for existing ones
-	for _, tagName := range tags {
-		var tag models.Tag
-		if err := tx.FirstOrCreate(&tag, models.Tag{Name: tagName}).Error; err != nil {
-			tx.Rollback()
-			return err
+	// Another request may create the tag meanwhile; then use theirs
+	tag = models.Tag{Name: name}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tag).Error; err != nil {
+		return nil, err
+	}
+	if tag.ID == 0 {
+		if err := tx.Where("LOWER(name) = LOWER(?)", name).Take(&tag).Error; err != nil {
+			return nil, err
 		}
+	}
+	return &tag, nil
+}
 
-		completed := existingCompletionStatus[tagName] // Get existing completion status if any
-		if err := tx.Create(&models.BookmarkTag{
-			BookmarkID: id,
-			TagID:      tag.ID,
-			Completed:  completed,
-		}).Error; err != nil {
-			tx.Rollback()
-			return err
+// AddTags adds tags to a bookmark, keeping the ones it already has
+func (s *BookmarkService) AddTags(id string, tags []string) error {
+	var names []string
+	if err := s.db.Model(&models.Tag{}).
+		Joins("JOIN bookmark_tags ON bookmark_tags.tag_id = tags.id").
+		Where("bookmark_tags.bookmark_id = ?", id).
+		Pluck("tags.name", &names).Error; err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		seen[strings.ToLower(name)] = true
+	}
+	added := false
+	for _, tag := range tags {
+		if key := strings.ToLower(models.NormalizeTagName(tag)); !seen[key] {
+			seen[key] = true
+			names = append(names, tag)
+			added = true
 		}
 	}
+	if !added {
+		return nil
+	}
 
-	return tx.Commit().Error
+	return s.UpdateTags(id, names)
 }
 
 func (s *BookmarkService) Delete(id string) error {
@@ -189,50 +260,96 @@ func (s *BookmarkService) RefreshView() error {
 	return s.db.Exec("REFRESH MATERIALIZED VIEW CONCURRENTLY bookmark_views").Error
 }
 
-// ListFromView gets bookmarks from the materialized view
-func (s *BookmarkService) ListFromView(tag string, search string, page string, limit string, showArchived bool) ([]models.BookmarkView, int64, error) {
+// ListFromView gets a page of bookmarks from the materialized view. Pages
+// are read with OFFSET by number, or with a keyset condition relative to a
+// cursor, which stays fast deep into the list and doesn't skip or repeat
+// rows when bookmarks are added while paging.
+func (s *BookmarkService) ListFromView(filter BookmarkFilter, sort BookmarkSort, page ListPage) (*ListResult, error) {
 	var bookmarks []models.BookmarkView
 	var total int64
 
-	query := s.db.Model(&models.BookmarkView{})
-
-	// Apply archived filter
-	query = query.Where("archived = ?", showArchived)
-
-	// Apply tag filter if provided
-	if tag != "" {
-		query = query.Where("tags_json::jsonb @> ?", fmt.Sprintf(`[{"name":"%s"}]`, tag))
-	}
-
-	// Apply search filter if provided
-	if search != "" {
-		query = query.Where("full_text ILIKE ? OR name ILIKE ? OR screen_name ILIKE ?",
-			"%"+search+"%", "%"+search+"%", "%"+search+"%")
+	query, search, err := s.filteredView(filter)
+	if err != nil {
+		return nil, err
 	}
+	sort = sort.resolve(search.text)
 
 	// Get total count
 	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 
-	// Parse pagination params
-	pageNum, _ := strconv.Atoi(page)
-	limitNum, _ := strconv.Atoi(limit)
-	offset := (pageNum - 1) * limitNum
+	forward := page.Before == ""
+	if token := page.After + page.Before; token != "" {
+		cursor, err := decodeCursor(token, sort)
+		if err != nil {
+			return nil, err
+		}
+		query = applyCursor(query, sort, cursor, search, forward)
+	} else {
+		query = query.Offset((page.Number - 1) * page.Limit)
+	}
 
-	// Execute final query
-	err := query.
-		Order("created_at DESC").
-		Offset(offset).
-		Limit(limitNum).
+	// Highlight full-text matches, and select the rank cursors encode
+	columns := "*"
+	var args []interface{}
+	if search.text != "" && !search.fuzzy {
+		columns += ", ts_headline('english', full_text, websearch_to_tsquery('english', ?), ?) AS snippet"
+		args = append(args, search.text, searchHeadlineOptions)
+	}
+	if sort.Key == SortRelevance {
+		rank, rankArgs := search.rank()
+		columns += ", " + rank + " AS rank"
+		args = append(args, rankArgs...)
+	}
+	if len(args) > 0 {
+		query = query.Select(columns, args...)
+	}
+
+	// Execute final query, reading one extra row to know whether more follow
+	err = query.
+		Order(sort.orderBy(search, !forward)).
+		Limit(page.Limit + 1).
 		Find(&bookmarks).Error
 
 	if err != nil {
-		return nil, 0, err
+		return nil, err
+	}
+
+	more := len(bookmarks) > page.Limit
+	if more {
+		bookmarks = bookmarks[:page.Limit]
+	}
+	if !forward {
+		for i, j := 0, len(bookmarks)-1; i < j; i, j = i+1, j-1 {
+			bookmarks[i], bookmarks[j] = bookmarks[j], bookmarks[i]
+		}
 	}
 
-	// Parse JSON fields into structs
+	result := &ListResult{Bookmarks: bookmarks, Total: total}
+	if len(bookmarks) > 0 {
+		first, last := &bookmarks[0], &bookmarks[len(bookmarks)-1]
+		if more || !forward {
+			result.NextCursor = encodeCursor(sort, last)
+		}
+		if (more && !forward) || (forward && (page.After != "" || page.Number > 1)) {
+			result.PrevCursor = encodeCursor(sort, first)
+		}
+	}
+
+	if err := s.decodeViews(bookmarks); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// decodeViews parses the JSON fields of view rows into structs and adds the
+// lists each bookmark belongs to
+func (s *BookmarkService) decodeViews(bookmarks []models.BookmarkView) error {
+	ids := make([]string, len(bookmarks))
 	for i := range bookmarks {
+		ids[i] = bookmarks[i].ID
 		if bookmarks[i].MediaJSON != "" {
 			if err := json.Unmarshal([]byte(bookmarks[i].MediaJSON), &bookmarks[i].Media); err != nil {
 				log.Printf("Error unmarshaling media JSON: %v", err)
@@ -245,5 +362,98 @@ func (s *BookmarkService) ListFromView(tag string, search string, page string, l
 		}
 	}
 
-	return bookmarks, total, nil
+	memberships, err := NewListService(s.db).Memberships(ids)
+	if err != nil {
+		return err
+	}
+	for i := range bookmarks {
+		bookmarks[i].Lists = nonNil(memberships[bookmarks[i].ID])
+	}
+	return nil
+}
+
+// Count returns how many bookmarks match a filter
+func (s *BookmarkService) Count(filter BookmarkFilter) (int64, error) {
+	query, _, err := s.filteredView(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountEach returns how many bookmarks match each filter, in one query.
+// Postgres still evaluates every filter against the view, so the cost grows
+// with the number of filters, but without a round trip each.
+func (s *BookmarkService) CountEach(filters []BookmarkFilter) ([]int64, error) {
+	counts := make([]int64, len(filters))
+	if len(filters) == 0 {
+		return counts, nil
+	}
+
+	hierarchy := lazyTagHierarchy(s.db)
+	columns := make([]string, len(filters))
+	args := make([]interface{}, len(filters))
+	dest := make([]interface{}, len(filters))
+	for i, filter := range filters {
+		query, _, err := s.filteredViewWith(filter, hierarchy)
+		if err != nil {
+			return nil, err
+		}
+		columns[i] = "(?)"
+		args[i] = query.Select("COUNT(*)")
+		dest[i] = &counts[i]
+	}
+
+	if err := s.db.Raw("SELECT "+strings.Join(columns, ", "), args...).Row().Scan(dest...); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// filteredView selects the materialized view rows matching a filter. The
+// search is parsed with the searchquery language: its operators become
+// conditions and the remaining text, returned for ranking, is matched with
+// full-text search or, for fuzzy filters, by similarity. Syntax errors are
+// returned as *searchquery.Error.
+func (s *BookmarkService) filteredView(filter BookmarkFilter) (*gorm.DB, textSearch, error) {
+	return s.filteredViewWith(filter, lazyTagHierarchy(s.db))
+}
+
+// filteredViewWith is filteredView taking the tag hierarchy from loadHierarchy,
+// so several filters can share one load
+func (s *BookmarkService) filteredViewWith(filter BookmarkFilter, loadHierarchy func() (*tagHierarchy, error)) (*gorm.DB, textSearch, error) {
+	parsed, err := searchquery.Parse(filter.Search)
+	if err != nil {
+		return nil, textSearch{}, err
+	}
+
+	query := s.db.Model(&models.BookmarkView{})
+
+	// Apply archived filter, unless the search asks with is:archived
+	if !parsed.Has(searchquery.KeyIs, "archived") {
+		query = query.Where("archived = ?", filter.Archived)
+	}
+
+	// Tag conditions match descendant tags too, which needs the hierarchy
+	var hierarchy *tagHierarchy
+	if len(filter.Tags) > 0 || len(filter.ExcludeTags) > 0 || parsed.HasKey(searchquery.KeyTag) {
+		if hierarchy, err = loadHierarchy(); err != nil {
+			return nil, textSearch{}, err
+		}
+	}
+
+	query = applyTagFilter(query, filter, hierarchy)
+	query = applySearchFilters(query, parsed.Filters(), hierarchy)
+
+	// Apply the text search if provided; websearch syntax supports quoted
+	// phrases, OR and -excluded words
+	search := newTextSearch(parsed, filter.Fuzzy)
+	query = search.apply(query, parsed)
+
+	return query, search, nil
 }
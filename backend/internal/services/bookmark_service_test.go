@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/helioLJ/tweetvault/internal/models"
+)
+
+func TestUpdateTagsKeepsExistingLinks(t *testing.T) {
+	db := testDB(t)
+	mustCreate(t, db, &models.Bookmark{ID: "1764000000000000001", CreatedAt: time.Now()})
+	service := NewBookmarkService(db)
+
+	if err := service.UpdateTags("1764000000000000001", []string{"Go", "Later"}); err != nil {
+		t.Fatal(err)
+	}
+	tagged := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := db.Model(&models.BookmarkTag{}).Where("bookmark_id = ?", "1764000000000000001").
+		Updates(map[string]interface{}{"created_at": tagged, "completed": true}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Dropping Later and spelling Go in lower case must leave its link untouched
+	if err := service.UpdateTags("1764000000000000001", []string{"go", "New"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var links []struct {
+		Name      string
+		CreatedAt time.Time
+		Completed bool
+	}
+	if err := db.Table("bookmark_tags").
+		Select("tags.name, bookmark_tags.created_at, bookmark_tags.completed").
+		Joins("JOIN tags ON tags.id = bookmark_tags.tag_id").
+		Where("bookmark_tags.bookmark_id = ?", "1764000000000000001").
+		Order("tags.name").
+		Scan(&links).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(links) != 2 || links[0].Name != "Go" || links[1].Name != "New" {
+		t.Fatalf("links = %+v, want Go and New", links)
+	}
+	if !links[0].CreatedAt.Equal(tagged) || !links[0].Completed {
+		t.Errorf("kept link = %+v, want created at %v and completed", links[0], tagged)
+	}
+	if !links[1].CreatedAt.After(tagged) || links[1].Completed {
+		t.Errorf("new link = %+v, want a fresh pending link", links[1])
+	}
+}
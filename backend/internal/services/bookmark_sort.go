@@ -0,0 +1,126 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+// Sort keys accepted by ParseSort
+const (
+	SortDate      = "date"      // Tweet date
+	SortImported  = "imported"  // When the bookmark was first imported
+	SortFavorites = "favorites" // Favorite count
+	SortRetweets  = "retweets"  // Retweet count
+	SortViews     = "views"     // View count
+	SortAuthor    = "author"    // Screen name
+	SortTagged    = "tagged"    // Most recent time a tag was added
+	SortRelevance = "relevance" // Full-text rank or similarity; only applies when searching
+)
+
+// sortColumns maps sort keys to view expressions and their default direction.
+// Each has a matching index on bookmark_views.
+var sortColumns = map[string]struct {
+	expression string
+	ascending  bool
+}{
+	SortDate:      {"created_at", false},
+	SortImported:  {"imported_at", false},
+	SortFavorites: {"favorite_count", false},
+	SortRetweets:  {"retweet_count", false},
+	SortViews:     {"views_count", false},
+	SortAuthor:    {"LOWER(screen_name)", true},
+	SortTagged:    {"tagged_at", false},
+	SortRelevance: {"", false},
+}
+
+// BookmarkSort orders the bookmark list. The zero value sorts by relevance
+// when searching and by tweet date otherwise.
+type BookmarkSort struct {
+	Key       string
+	Ascending bool
+}
+
+// ParseSort validates a sort key and an asc/desc order, which defaults to
+// the natural direction of the key
+func ParseSort(key, order string) (BookmarkSort, error) {
+	if key == "" {
+		if order != "" {
+			return BookmarkSort{}, fmt.Errorf("order needs a sort key")
+		}
+		return BookmarkSort{}, nil
+	}
+
+	column, ok := sortColumns[key]
+	if !ok {
+		return BookmarkSort{}, fmt.Errorf("invalid sort %q: must be one of %s", key, strings.Join(sortKeys(), ", "))
+	}
+
+	result := BookmarkSort{Key: key, Ascending: column.ascending}
+	switch order {
+	case "":
+	case "asc":
+		result.Ascending = true
+	case "desc":
+		result.Ascending = false
+	default:
+		return BookmarkSort{}, fmt.Errorf("invalid order %q: must be asc or desc", order)
+	}
+	return result, nil
+}
+
+func sortKeys() []string {
+	keys := make([]string, 0, len(sortColumns))
+	for key := range sortColumns {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// resolve fills in the default key; relevance falls back to the tweet date
+// when there is no text to rank by
+func (s BookmarkSort) resolve(text string) BookmarkSort {
+	if s.Key == "" || s.Key == SortRelevance {
+		if text != "" {
+			return BookmarkSort{Key: SortRelevance, Ascending: s.Ascending}
+		}
+		return BookmarkSort{Key: SortDate, Ascending: s.Ascending}
+	}
+	return s
+}
+
+// orderBy builds the ORDER BY clause of a resolved sort, with the tweet ID
+// breaking ties so the order is stable across pages. reverse flips it, which
+// cursor pagination uses to read the rows before a cursor.
+func (s BookmarkSort) orderBy(search textSearch, reverse bool) clause.OrderBy {
+	direction := "DESC"
+	if s.Ascending != reverse {
+		direction = "ASC"
+	}
+
+	var sql string
+	var vars []interface{}
+	switch s.Key {
+	case SortRelevance:
+		rank, rankVars := search.rank()
+		sql = fmt.Sprintf("%[1]s %[2]s, created_at %[2]s", rank, direction)
+		vars = rankVars
+	case SortTagged:
+		// Never-tagged bookmarks go last in both directions
+		nulls := "NULLS LAST"
+		if reverse {
+			nulls = "NULLS FIRST"
+		}
+		sql = fmt.Sprintf("tagged_at %s %s", direction, nulls)
+	default:
+		sql = fmt.Sprintf("%s %s", sortColumns[s.Key].expression, direction)
+	}
+
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL:  fmt.Sprintf("%s, id %s", sql, direction),
+		Vars: vars,
+	}}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/helioLJ/tweetvault/internal/importers"
+	"github.com/helioLJ/tweetvault/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// overwriteColumns are the imported columns replaced by the overwrite merge
+// policy; archived is deliberately absent since it belongs to the user
+var overwriteColumns = []string{
+	"created_at", "full_text", "screen_name", "name", "profile_image_url",
+	"favorite_count", "retweet_count", "bookmark_count", "quote_count", "reply_count", "views_count",
+	"favorited", "retweeted", "bookmarked", "url", "metadata",
+}
+
+// processBookmark writes one imported bookmark and its media according to the
+// import options, returning what changed for the job report
+func processBookmark(tx *gorm.DB, item *importers.Item, archive *importers.Archive, opts ImportOptions) (*bookmarkResult, error) {
+	if item.Err != nil {
+		return nil, item.Err
+	}
+
+	bookmark := &item.Bookmark
+	result := &bookmarkResult{
+		BookmarkID:  bookmark.ID,
+		InvalidDate: item.InvalidDate,
+	}
+
+	// Use Session to temporarily disable error logging for expected misses
+	quiet := tx.Session(&gorm.Session{Logger: tx.Logger.LogMode(logger.Silent)})
+
+	var existing models.Bookmark
+	err := quiet.Omit("metadata").Where("id = ?", bookmark.ID).Take(&existing).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		result.New = true
+	case err != nil:
+		return nil, fmt.Errorf("error checking existing bookmark: %w", err)
+	default:
+		result.Changes = applyMergePolicy(opts.MergePolicy, diffBookmarks(&existing, bookmark))
+	}
+
+	if !result.New && opts.MergePolicy == models.MergeSkip {
+		result.Skipped = true
+		return result, nil
+	}
+
+	if !opts.DryRun {
+		if err := saveBookmark(tx, bookmark, result.New, opts.MergePolicy); err != nil {
+			return nil, err
+		}
+	}
+
+	// Tags from the export are only ever added, never removed
+	if len(item.Tags) > 0 && !opts.DryRun {
+		if err := NewBookmarkService(tx).AddTags(bookmark.ID, item.Tags); err != nil {
+			return nil, fmt.Errorf("failed to add tags: %w", err)
+		}
+	}
+
+	// Process media files from ZIP
+	for _, m := range item.Media {
+		// Check if media already exists
+		var existingMedia models.Media
+		err := quiet.Select("id").Where("tweet_id = ? AND file_name = ?", bookmark.ID, m.Media.FileName).
+			Take(&existingMedia).Error
+
+		if err == nil {
+			// Media already exists, skip creating a new one
+			continue
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			// Only return error if it's not a "record not found" error
+			return nil, fmt.Errorf("error checking existing media: %w", err)
+		}
+
+		// Record media the export references but the ZIP doesn't contain
+		if archive == nil || !archive.Has(m.Entry) {
+			result.MissingMedia = append(result.MissingMedia, m.Media.FileName)
+			continue
+		}
+
+		if opts.DryRun {
+			continue
+		}
+
+		mediaData, err := archive.ReadFile(m.Entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract media file %s: %w", m.Entry, err)
+		}
+
+		media := m.Media
+		media.TweetID = bookmark.ID
+		media.FileData = mediaData
+
+		if err := tx.Create(&media).Error; err != nil {
+			return nil, fmt.Errorf("failed to create media record: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// saveBookmark creates a new bookmark or updates an existing one according to
+// the merge policy, leaving archive status and tags alone
+func saveBookmark(tx *gorm.DB, bookmark *models.Bookmark, isNew bool, policy string) error {
+	if isNew {
+		return tx.Create(bookmark).Error
+	}
+
+	update := tx.Model(&models.Bookmark{}).Where("id = ?", bookmark.ID)
+	switch policy {
+	case models.MergeMetrics:
+		return update.Updates(map[string]interface{}{
+			"favorite_count": bookmark.FavoriteCount,
+			"retweet_count":  bookmark.RetweetCount,
+			"bookmark_count": bookmark.BookmarkCount,
+			"quote_count":    bookmark.QuoteCount,
+			"reply_count":    bookmark.ReplyCount,
+			"views_count":    bookmark.ViewsCount,
+		}).Error
+	default:
+		return update.Select(overwriteColumns).Updates(bookmark).Error
+	}
+}
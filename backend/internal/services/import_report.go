@@ -0,0 +1,169 @@
+package services
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/helioLJ/tweetvault/internal/models"
+)
+
+// reportDetailLimit caps each detail list in an import report so re-importing
+// a large export doesn't produce a multi-megabyte job row
+const reportDetailLimit = 500
+
+// bookmarkResult is what importing a single bookmark did, or would do
+type bookmarkResult struct {
+	BookmarkID   string
+	New          bool
+	Skipped      bool
+	Changes      []models.FieldChange
+	MissingMedia []string
+	InvalidDate  *models.InvalidDate
+}
+
+// recordResult folds a successfully imported bookmark into the job report
+func recordResult(report *models.ImportReport, result *bookmarkResult) {
+	if result.New {
+		report.New++
+	} else {
+		report.Existing++
+		if result.Skipped {
+			report.Skipped++
+		}
+		if len(result.Changes) > 0 {
+			report.Changed++
+			if report.FieldChanges == nil {
+				report.FieldChanges = make(map[string]int)
+			}
+			for _, change := range result.Changes {
+				report.FieldChanges[change.Field]++
+			}
+			if len(report.Changes) < reportDetailLimit {
+				report.Changes = append(report.Changes, models.BookmarkChange{
+					BookmarkID: result.BookmarkID,
+					Fields:     result.Changes,
+				})
+			} else {
+				report.Truncated = true
+			}
+		}
+	}
+
+	for _, fileName := range result.MissingMedia {
+		if len(report.MissingMedia) < reportDetailLimit {
+			report.MissingMedia = append(report.MissingMedia, models.MissingMedia{
+				BookmarkID: result.BookmarkID,
+				FileName:   fileName,
+			})
+		} else {
+			report.Truncated = true
+		}
+	}
+
+	if result.InvalidDate != nil {
+		if len(report.InvalidDates) < reportDetailLimit {
+			report.InvalidDates = append(report.InvalidDates, *result.InvalidDate)
+		} else {
+			report.Truncated = true
+		}
+	}
+}
+
+// mergedReport returns a copy of a job report with a batch report added,
+// leaving both unchanged
+func mergedReport(report, batch models.ImportReport) models.ImportReport {
+	merged := report
+	merged.New += batch.New
+	merged.Existing += batch.Existing
+	merged.Skipped += batch.Skipped
+	merged.Changed += batch.Changed
+	if len(batch.FieldChanges) > 0 {
+		merged.FieldChanges = maps.Clone(report.FieldChanges)
+		if merged.FieldChanges == nil {
+			merged.FieldChanges = make(map[string]int, len(batch.FieldChanges))
+		}
+		for field, count := range batch.FieldChanges {
+			merged.FieldChanges[field] += count
+		}
+	}
+	merged.Truncated = report.Truncated || batch.Truncated
+	merged.Changes = appendDetails(report.Changes, batch.Changes, &merged.Truncated)
+	merged.MissingMedia = appendDetails(report.MissingMedia, batch.MissingMedia, &merged.Truncated)
+	merged.InvalidDates = appendDetails(report.InvalidDates, batch.InvalidDates, &merged.Truncated)
+	return merged
+}
+
+// appendDetails appends to a copy of a report detail list up to
+// reportDetailLimit, flagging the report truncated when details are dropped
+func appendDetails[T any](details, more []T, truncated *bool) []T {
+	if len(more) == 0 {
+		return details
+	}
+	room := max(reportDetailLimit-len(details), 0)
+	if len(more) > room {
+		more = more[:room]
+		*truncated = true
+	}
+	return append(slices.Clip(details), more...)
+}
+
+// diffBookmarks lists the imported fields whose values differ from the stored bookmark
+func diffBookmarks(existing, incoming *models.Bookmark) []models.FieldChange {
+	var changes []models.FieldChange
+
+	addString := func(field, old, new string) {
+		if old != new {
+			changes = append(changes, models.FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+	addInt := func(field string, old, new int) {
+		if old != new {
+			changes = append(changes, models.FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+
+	if !existing.CreatedAt.Equal(incoming.CreatedAt) {
+		changes = append(changes, models.FieldChange{Field: "created_at", Old: existing.CreatedAt, New: incoming.CreatedAt})
+	}
+	addString("full_text", existing.FullText, incoming.FullText)
+	addString("screen_name", existing.ScreenName, incoming.ScreenName)
+	addString("name", existing.Name, incoming.Name)
+	addString("profile_image_url", existing.ProfileImageURL, incoming.ProfileImageURL)
+	addString("url", existing.URL, incoming.URL)
+	addInt("favorite_count", existing.FavoriteCount, incoming.FavoriteCount)
+	addInt("retweet_count", existing.RetweetCount, incoming.RetweetCount)
+	addInt("bookmark_count", existing.BookmarkCount, incoming.BookmarkCount)
+	addInt("quote_count", existing.QuoteCount, incoming.QuoteCount)
+	addInt("reply_count", existing.ReplyCount, incoming.ReplyCount)
+	addInt("views_count", existing.ViewsCount, incoming.ViewsCount)
+
+	return changes
+}
+
+// metricFields are the engagement counters refreshed by the metrics merge policy
+var metricFields = map[string]bool{
+	"favorite_count": true,
+	"retweet_count":  true,
+	"bookmark_count": true,
+	"quote_count":    true,
+	"reply_count":    true,
+	"views_count":    true,
+}
+
+// applyMergePolicy narrows the detected changes to the ones the policy writes
+func applyMergePolicy(policy string, changes []models.FieldChange) []models.FieldChange {
+	switch policy {
+	case models.MergeSkip:
+		return nil
+	case models.MergeMetrics:
+		var applied []models.FieldChange
+		for _, change := range changes {
+			if metricFields[change.Field] {
+				applied = append(applied, change)
+			}
+		}
+		return applied
+	default:
+		return changes
+	}
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/helioLJ/tweetvault/internal/models"
+)
+
+func TestMergedReport(t *testing.T) {
+	job := models.ImportReport{
+		MergePolicy:  models.MergeSkip,
+		New:          1,
+		FieldChanges: map[string]int{"full_text": 1},
+		Changes:      make([]models.BookmarkChange, reportDetailLimit-1, reportDetailLimit+10),
+	}
+	var batch models.ImportReport
+	recordResult(&batch, &bookmarkResult{BookmarkID: "1", New: true})
+	recordResult(&batch, &bookmarkResult{BookmarkID: "2", Changes: []models.FieldChange{{Field: "full_text"}}})
+	recordResult(&batch, &bookmarkResult{BookmarkID: "3", Changes: []models.FieldChange{{Field: "url"}}})
+	before := job
+	before.FieldChanges = map[string]int{"full_text": 1}
+
+	merged := mergedReport(job, batch)
+
+	if merged.New != 2 || merged.Existing != 2 || merged.Changed != 2 {
+		t.Errorf("counts = %d new, %d existing, %d changed, want 2, 2, 2", merged.New, merged.Existing, merged.Changed)
+	}
+	if want := map[string]int{"full_text": 2, "url": 1}; !reflect.DeepEqual(merged.FieldChanges, want) {
+		t.Errorf("field changes = %v, want %v", merged.FieldChanges, want)
+	}
+	if len(merged.Changes) != reportDetailLimit || !merged.Truncated {
+		t.Errorf("%d changes, truncated %v, want %d and truncated", len(merged.Changes), merged.Truncated, reportDetailLimit)
+	}
+	if merged.MergePolicy != models.MergeSkip {
+		t.Errorf("merge policy = %q, want it kept", merged.MergePolicy)
+	}
+
+	// The job report stays as it was until the batch commits
+	if !reflect.DeepEqual(job, before) {
+		t.Errorf("job report changed to %+v", job)
+	}
+	if extended := job.Changes[:reportDetailLimit]; extended[reportDetailLimit-1].BookmarkID != "" {
+		t.Errorf("merge wrote into the job report's backing array")
+	}
+}
@@ -0,0 +1,478 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/helioLJ/tweetvault/internal/importers"
+	"github.com/helioLJ/tweetvault/internal/models"
+	"gorm.io/gorm"
+)
+
+// importBatchSize is how many bookmarks are committed per transaction. Progress
+// is saved in the same transaction and the cancellation flag re-read after
+// every batch.
+const importBatchSize = 100
+
+var errImportCancelled = errors.New("import cancelled")
+
+// ErrUnknownFormat is returned when an upload asks for an importer that isn't registered
+var ErrUnknownFormat = errors.New("unknown import format")
+
+// ImportOptions are chosen per upload
+type ImportOptions struct {
+	DryRun      bool   // Parse and report without writing bookmarks or media
+	MergePolicy string // One of models.MergeSkip, MergeMetrics or MergeOverwrite
+	Format      string // Importer name; detected from the files when empty
+	CSVMapping  string // JSON object mapping CSV headers onto bookmark fields
+}
+
+// ParseMergePolicy validates a merge policy name, defaulting to overwrite
+func ParseMergePolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return models.MergeOverwrite, nil
+	case models.MergeSkip, models.MergeMetrics, models.MergeOverwrite:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid merge policy %q: must be %s, %s or %s",
+			policy, models.MergeSkip, models.MergeMetrics, models.MergeOverwrite)
+	}
+}
+
+type ImportService struct {
+	db  *gorm.DB
+	dir string
+}
+
+func NewImportService(db *gorm.DB, dir string) *ImportService {
+	return &ImportService{db: db, dir: dir}
+}
+
+// CreateJob stores the uploaded files on disk, picks the importer for them and
+// queues an import job. dataFile is nil for X archives, which are a single ZIP,
+// and zipFile is nil for exports uploaded without media.
+func (s *ImportService) CreateJob(dataFile, zipFile *multipart.FileHeader, opts ImportOptions) (*models.ImportJob, error) {
+	if dataFile == nil && zipFile == nil {
+		return nil, fmt.Errorf("%w: no files uploaded", importers.ErrUnrecognized)
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create import directory: %w", err)
+	}
+
+	storagePath, err := os.MkdirTemp(s.dir, "import-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import directory: %w", err)
+	}
+
+	job := &models.ImportJob{
+		Phase:       models.ImportQueued,
+		StoragePath: storagePath,
+		DryRun:      opts.DryRun,
+		MergePolicy: opts.MergePolicy,
+		Report:      models.ImportReport{MergePolicy: opts.MergePolicy},
+	}
+
+	if opts.CSVMapping != "" {
+		job.ImporterOptions = models.StringMap{importers.CSVMappingOption: opts.CSVMapping}
+	}
+
+	if dataFile != nil {
+		job.DataFileName = dataFile.Filename
+		if err := saveUploadedFile(dataFile, job.DataPath()); err != nil {
+			os.RemoveAll(storagePath)
+			return nil, fmt.Errorf("failed to store data file: %w", err)
+		}
+	}
+	if zipFile != nil {
+		job.ZipFileName = zipFile.Filename
+		if err := saveUploadedFile(zipFile, job.ZipPath()); err != nil {
+			os.RemoveAll(storagePath)
+			return nil, fmt.Errorf("failed to store ZIP file: %w", err)
+		}
+	}
+
+	importer, err := selectImporter(job, opts.Format)
+	if err != nil {
+		os.RemoveAll(storagePath)
+		return nil, err
+	}
+	job.Format = importer.Name()
+
+	if err := s.db.Create(job).Error; err != nil {
+		os.RemoveAll(storagePath)
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// Get returns an import job together with its per-item failures
+func (s *ImportService) Get(id string) (*models.ImportJob, error) {
+	var job models.ImportJob
+	if err := s.db.Preload("Failures", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).First(&job, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// List returns the most recent import jobs without their failures
+func (s *ImportService) List(limit int) ([]models.ImportJob, error) {
+	jobs := []models.ImportJob{}
+	if err := s.db.Order("id DESC").Limit(limit).Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Cancel stops a queued job immediately and asks a running one to stop at
+// its next progress update
+func (s *ImportService) Cancel(id string) (*models.ImportJob, error) {
+	job, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.Finished() {
+		return job, nil
+	}
+
+	if job.Phase == models.ImportQueued {
+		result := s.db.Model(&models.ImportJob{}).
+			Where("id = ? AND phase = ?", job.ID, models.ImportQueued).
+			Updates(map[string]interface{}{
+				"phase":            models.ImportCancelled,
+				"cancel_requested": true,
+				"finished_at":      time.Now(),
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			os.RemoveAll(job.StoragePath)
+			return s.Get(id)
+		}
+		// The worker claimed the job in the meantime, fall through
+	}
+
+	if err := s.db.Model(&models.ImportJob{}).Where("id = ?", job.ID).
+		Update("cancel_requested", true).Error; err != nil {
+		return nil, err
+	}
+
+	return s.Get(id)
+}
+
+// ClaimNext atomically moves the oldest queued job into the parsing phase and
+// returns it, or returns nil when nothing is queued
+func (s *ImportService) ClaimNext() (*models.ImportJob, error) {
+	var jobs []models.ImportJob
+	err := s.db.Raw(`
+		UPDATE import_jobs
+		SET phase = ?, started_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM import_jobs
+			WHERE phase = ?
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *
+	`, models.ImportParsing, models.ImportQueued).Scan(&jobs).Error
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
+
+// RequeueInterrupted puts jobs that were running when the server stopped back
+// in the queue. Their counters, report and failures are kept: each batch saves
+// them together with its bookmarks, so the job resumes after the last
+// committed batch instead of counting those bookmarks again as existing.
+func (s *ImportService) RequeueInterrupted() error {
+	if err := s.renameLegacyDataFiles(); err != nil {
+		return err
+	}
+
+	result := s.db.Model(&models.ImportJob{}).
+		Where("phase IN ?", []string{models.ImportParsing, models.ImportImporting}).
+		Updates(map[string]interface{}{
+			"phase":      models.ImportQueued,
+			"started_at": nil,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Requeued %d interrupted import job(s)", result.RowsAffected)
+	}
+	return nil
+}
+
+// renameLegacyDataFiles moves the data files of unfinished jobs queued
+// before they were stored under DataPath, when every upload was saved as
+// bookmarks.json
+func (s *ImportService) renameLegacyDataFiles() error {
+	var jobs []models.ImportJob
+	if err := s.db.Where("phase IN ? AND data_file_name <> ''",
+		[]string{models.ImportQueued, models.ImportParsing, models.ImportImporting}).
+		Find(&jobs).Error; err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		legacy := filepath.Join(job.StoragePath, "bookmarks.json")
+		path := job.DataPath()
+		if path == legacy {
+			continue
+		}
+		if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err := os.Rename(legacy, path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Import job %d: error renaming its data file: %v", job.ID, err)
+		}
+	}
+	return nil
+}
+
+// Run processes a claimed job to completion, recording the final phase
+func (s *ImportService) Run(job *models.ImportJob) {
+	defer os.RemoveAll(job.StoragePath)
+
+	err := s.run(job)
+
+	phase := models.ImportCompleted
+	errMsg := ""
+	switch {
+	case errors.Is(err, errImportCancelled):
+		phase = models.ImportCancelled
+	case err != nil:
+		phase = models.ImportFailed
+		errMsg = err.Error()
+		log.Printf("Import job %d failed: %v", job.ID, err)
+	}
+
+	if err := s.db.Model(&models.ImportJob{}).Where("id = ?", job.ID).
+		Updates(map[string]interface{}{
+			"phase":        phase,
+			"total":        job.Total,
+			"processed":    job.Processed,
+			"failed_count": job.FailedCount,
+			"error":        errMsg,
+			"report":       job.Report,
+			"finished_at":  time.Now(),
+		}).Error; err != nil {
+		log.Printf("Error finishing import job %d: %v", job.ID, err)
+	}
+
+	if job.Processed > 0 && !job.DryRun {
+		if err := NewBookmarkService(s.db).RefreshView(); err != nil {
+			log.Printf("Error refreshing materialized view after import %d: %v", job.ID, err)
+		}
+	}
+}
+
+func (s *ImportService) run(job *models.ImportJob) error {
+	importer, ok := importers.Get(job.Format)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownFormat, job.Format)
+	}
+
+	upload, err := openUpload(job)
+	if err != nil {
+		return err
+	}
+	if upload.Archive != nil {
+		defer upload.Archive.Close()
+	}
+
+	source, total, err := importer.Open(upload)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s export: %w", job.Format, err)
+	}
+	defer source.Close()
+
+	job.Total = total
+	if err := s.db.Model(&models.ImportJob{}).Where("id = ?", job.ID).
+		Updates(map[string]interface{}{
+			"phase": models.ImportImporting,
+			"total": job.Total,
+		}).Error; err != nil {
+		return err
+	}
+
+	// A requeued job skips the bookmarks its committed batches already covered
+	resume := job.Processed
+	if resume > 0 {
+		log.Printf("Import job %d: resuming after %d processed bookmarks", job.ID, resume)
+	}
+
+	batch := make([]importers.Item, 0, importBatchSize)
+	for {
+		var item importers.Item
+		more, err := source.Next(&item)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s export: %w", job.Format, err)
+		}
+		if more && resume > 0 {
+			resume--
+			continue
+		}
+		if more {
+			batch = append(batch, item)
+		}
+
+		if len(batch) == importBatchSize || (!more && len(batch) > 0) {
+			if progress, ok := source.(importers.ProgressSource); ok && total == 0 {
+				job.Total = estimateTotal(job.Processed+len(batch), progress.Progress())
+			}
+			if err := s.importBatch(job, batch, upload.Archive); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+
+		if !more {
+			job.Total = job.Processed
+			return nil
+		}
+	}
+}
+
+// estimateTotal extrapolates the number of bookmarks in an upload from how
+// many were read in the given fraction of it
+func estimateTotal(read int, progress float64) int {
+	if progress <= 0 {
+		return read
+	}
+	return max(read, int(float64(read)/progress+0.5))
+}
+
+// importBatch commits a batch of bookmarks in one transaction together with
+// the job's progress. Each bookmark runs under a savepoint so a bad item is
+// recorded as a failure and rolled back without aborting the rest of the
+// batch. The job's counters and report only change once the batch commits.
+// It returns errImportCancelled when a cancellation was requested.
+func (s *ImportService) importBatch(job *models.ImportJob, batch []importers.Item, archive *importers.Archive) error {
+	var failures []models.ImportFailure
+	var report models.ImportReport
+	var cancelRequested bool
+	opts := ImportOptions{DryRun: job.DryRun, MergePolicy: job.MergePolicy}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var batchReport models.ImportReport
+		for i := range batch {
+			item := &batch[i]
+			if err := tx.SavePoint("bookmark").Error; err != nil {
+				return err
+			}
+
+			result, err := processBookmark(tx, item, archive, opts)
+			if err != nil {
+				log.Printf("Import job %d: error processing bookmark %s: %v", job.ID, item.Bookmark.ID, err)
+				if err := tx.RollbackTo("bookmark").Error; err != nil {
+					return err
+				}
+				failures = append(failures, models.ImportFailure{
+					ImportJobID: job.ID,
+					BookmarkID:  item.Bookmark.ID,
+					Error:       err.Error(),
+				})
+				continue
+			}
+			recordResult(&batchReport, result)
+		}
+
+		if len(failures) > 0 {
+			if err := tx.Create(&failures).Error; err != nil {
+				return err
+			}
+		}
+
+		report = mergedReport(job.Report, batchReport)
+		return tx.Raw(`
+			UPDATE import_jobs
+			SET processed = ?, failed_count = ?, total = ?, report = ?, updated_at = NOW()
+			WHERE id = ?
+			RETURNING cancel_requested
+		`, job.Processed+len(batch), job.FailedCount+len(failures), job.Total, report, job.ID).Scan(&cancelRequested).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	job.Report = report
+	job.Processed += len(batch)
+	job.FailedCount += len(failures)
+	if cancelRequested {
+		return errImportCancelled
+	}
+	return nil
+}
+
+// openUpload opens the stored files of a job for an importer
+func openUpload(job *models.ImportJob) (*importers.Upload, error) {
+	upload := &importers.Upload{DataFileName: job.DataFileName, Options: job.ImporterOptions}
+	if job.DataFileName != "" {
+		upload.DataPath = job.DataPath()
+	}
+	if job.ZipFileName != "" {
+		archive, err := importers.OpenArchive(job.ZipPath())
+		if err != nil {
+			return nil, err
+		}
+		upload.Archive = archive
+	}
+	return upload, nil
+}
+
+// selectImporter returns the requested importer, or sniffs the stored files
+// to find one when no format was given
+func selectImporter(job *models.ImportJob, format string) (importers.Importer, error) {
+	if format != "" {
+		importer, ok := importers.Get(format)
+		if !ok {
+			return nil, fmt.Errorf("%w %q: must be one of %v", ErrUnknownFormat, format, importers.Names())
+		}
+		return importer, nil
+	}
+
+	upload, err := openUpload(job)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", importers.ErrUnrecognized, err)
+	}
+	if upload.Archive != nil {
+		defer upload.Archive.Close()
+	}
+
+	return importers.Detect(upload)
+}
+
+func saveUploadedFile(file *multipart.FileHeader, dst string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, src); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/helioLJ/tweetvault/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidList wraps validation errors of list names and orders
+	ErrInvalidList = errors.New("invalid list")
+	// ErrBookmarkNotFound is returned when adding a bookmark that doesn't exist
+	ErrBookmarkNotFound = errors.New("bookmark not found")
+	// ErrNotInList is returned for bookmarks that aren't in the list
+	ErrNotInList = errors.New("bookmark is not in the list")
+)
+
+// listColumns selects lists with their number of bookmarks
+const listColumns = "lists.*, (SELECT COUNT(*) FROM list_bookmarks lb WHERE lb.list_id = lists.id) AS bookmark_count"
+
+type ListService struct {
+	db *gorm.DB
+}
+
+func NewListService(db *gorm.DB) *ListService {
+	return &ListService{db: db}
+}
+
+// List returns every list by name with its bookmark count
+func (s *ListService) List() ([]models.List, error) {
+	lists := []models.List{}
+	if err := s.db.Select(listColumns).Order("name, id").Find(&lists).Error; err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (s *ListService) Get(id uint) (*models.List, error) {
+	var list models.List
+	if err := s.db.Select(listColumns).First(&list, id).Error; err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (s *ListService) Create(name string) (*models.List, error) {
+	name, err := validateListName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	list := models.List{Name: name}
+	if err := s.db.Create(&list).Error; err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (s *ListService) Rename(id uint, name string) (*models.List, error) {
+	name, err := validateListName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := s.db.Model(&models.List{}).Where("id = ?", id).Update("name", name)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return s.Get(id)
+}
+
+// Delete removes a list; its bookmarks are kept
+func (s *ListService) Delete(id uint) error {
+	result := s.db.Delete(&models.List{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Bookmarks returns a page of a list's bookmarks in position order
+func (s *ListService) Bookmarks(id uint, page ListPage) ([]models.BookmarkView, int64, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, 0, err
+	}
+
+	query := s.db.Model(&models.BookmarkView{}).
+		Joins("JOIN list_bookmarks lb ON lb.bookmark_id = bookmark_views.id AND lb.list_id = ?", id)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	bookmarks := []models.BookmarkView{}
+	if err := query.Select("bookmark_views.*").
+		Order("lb.position, bookmark_views.id").
+		Offset((page.Number - 1) * page.Limit).
+		Limit(page.Limit).
+		Find(&bookmarks).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := NewBookmarkService(s.db).decodeViews(bookmarks); err != nil {
+		return nil, 0, err
+	}
+	return bookmarks, total, nil
+}
+
+// AddBookmark puts a bookmark in a list at a position, or at the end when
+// position is nil. A bookmark already in the list is moved instead.
+func (s *ListService) AddBookmark(id uint, bookmarkID string, position *int) error {
+	return s.reorder(id, func(order []string) ([]string, error) {
+		var exists int64
+		if err := s.db.Model(&models.Bookmark{}).Where("id = ?", bookmarkID).Count(&exists).Error; err != nil {
+			return nil, err
+		}
+		if exists == 0 {
+			return nil, ErrBookmarkNotFound
+		}
+
+		order = without(order, bookmarkID)
+		at := len(order)
+		if position != nil {
+			at = clamp(*position, 0, len(order))
+		}
+		return insertAt(order, at, bookmarkID), nil
+	})
+}
+
+// MoveBookmark moves a bookmark of a list to a position, shifting the ones
+// in between, as when it is dragged
+func (s *ListService) MoveBookmark(id uint, bookmarkID string, position int) error {
+	return s.reorder(id, func(order []string) ([]string, error) {
+		rest := without(order, bookmarkID)
+		if len(rest) == len(order) {
+			return nil, ErrNotInList
+		}
+		return insertAt(rest, clamp(position, 0, len(rest)), bookmarkID), nil
+	})
+}
+
+// RemoveBookmark takes a bookmark out of a list, closing the gap it leaves
+func (s *ListService) RemoveBookmark(id uint, bookmarkID string) error {
+	return s.reorder(id, func(order []string) ([]string, error) {
+		rest := without(order, bookmarkID)
+		if len(rest) == len(order) {
+			return nil, ErrNotInList
+		}
+		return rest, nil
+	})
+}
+
+// Reorder sets the order of a list's bookmarks. It must name each of them
+// exactly once.
+func (s *ListService) Reorder(id uint, bookmarkIDs []string) error {
+	return s.reorder(id, func(order []string) ([]string, error) {
+		current := make(map[string]bool, len(order))
+		for _, bookmarkID := range order {
+			current[bookmarkID] = true
+		}
+
+		seen := make(map[string]bool, len(bookmarkIDs))
+		for _, bookmarkID := range bookmarkIDs {
+			if !current[bookmarkID] {
+				return nil, fmt.Errorf("%w: bookmark %s is not in the list", ErrInvalidList, bookmarkID)
+			}
+			if seen[bookmarkID] {
+				return nil, fmt.Errorf("%w: bookmark %s is listed twice", ErrInvalidList, bookmarkID)
+			}
+			seen[bookmarkID] = true
+		}
+		if len(seen) != len(order) {
+			return nil, fmt.Errorf("%w: the order must include all %d bookmarks of the list", ErrInvalidList, len(order))
+		}
+		return bookmarkIDs, nil
+	})
+}
+
+// reorder runs change on the current order of a list's bookmarks and stores
+// the order it returns, renumbering positions from 0. The list row is locked
+// so concurrent changes apply one after the other.
+func (s *ListService) reorder(id uint, change func(order []string) ([]string, error)) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var list models.List
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&list, id).Error; err != nil {
+			return err
+		}
+
+		var entries []models.ListBookmark
+		if err := tx.Where("list_id = ?", id).Order("position, bookmark_id").Find(&entries).Error; err != nil {
+			return err
+		}
+		order := make([]string, len(entries))
+		positions := make(map[string]int, len(entries))
+		for i, entry := range entries {
+			order[i] = entry.BookmarkID
+			positions[entry.BookmarkID] = entry.Position
+		}
+
+		next, err := change(order)
+		if err != nil {
+			return err
+		}
+
+		kept := make(map[string]bool, len(next))
+		for _, bookmarkID := range next {
+			kept[bookmarkID] = true
+		}
+		for _, bookmarkID := range order {
+			if !kept[bookmarkID] {
+				if err := tx.Delete(&models.ListBookmark{}, "list_id = ? AND bookmark_id = ?", id, bookmarkID).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		for position, bookmarkID := range next {
+			current, listed := positions[bookmarkID]
+			switch {
+			case !listed:
+				if err := tx.Create(&models.ListBookmark{ListID: id, BookmarkID: bookmarkID, Position: position}).Error; err != nil {
+					return err
+				}
+			case current != position:
+				if err := tx.Model(&models.ListBookmark{}).
+					Where("list_id = ? AND bookmark_id = ?", id, bookmarkID).
+					Update("position", position).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return tx.Model(&list).Update("updated_at", gorm.Expr("NOW()")).Error
+	})
+}
+
+// Memberships returns the lists each of the bookmarks belongs to, by name
+func (s *ListService) Memberships(bookmarkIDs []string) (map[string][]models.ListMembership, error) {
+	result := make(map[string][]models.ListMembership)
+	if len(bookmarkIDs) == 0 {
+		return result, nil
+	}
+
+	var memberships []models.ListMembership
+	if err := s.db.Table("list_bookmarks lb").
+		Select("lists.id, lists.name, lb.position, lb.bookmark_id").
+		Joins("JOIN lists ON lists.id = lb.list_id").
+		Where("lb.bookmark_id IN ?", bookmarkIDs).
+		Order("lists.name, lists.id").
+		Scan(&memberships).Error; err != nil {
+		return nil, err
+	}
+
+	for _, membership := range memberships {
+		result[membership.BookmarkID] = append(result[membership.BookmarkID], membership)
+	}
+	return result, nil
+}
+
+func validateListName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name is required", ErrInvalidList)
+	}
+	if len([]rune(name)) > 100 {
+		return "", fmt.Errorf("%w: name must be at most 100 characters", ErrInvalidList)
+	}
+	return name, nil
+}
+
+func without(order []string, bookmarkID string) []string {
+	rest := make([]string, 0, len(order))
+	for _, id := range order {
+		if id != bookmarkID {
+			rest = append(rest, id)
+		}
+	}
+	return rest
+}
+
+func insertAt(order []string, at int, bookmarkID string) []string {
+	order = append(order, "")
+	copy(order[at+1:], order[at:])
+	order[at] = bookmarkID
+	return order
+}
+
+func clamp(value, low, high int) int {
+	if value < low {
+		return low
+	}
+	if value > high {
+		return high
+	}
+	return value
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/helioLJ/tweetvault/internal/models"
+	"github.com/helioLJ/tweetvault/internal/searchquery"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidSavedSearch wraps validation errors of a saved search's filters
+	ErrInvalidSavedSearch = errors.New("invalid saved search")
+	// ErrSavedSearchExists is returned when the name is already taken
+	ErrSavedSearchExists = errors.New("a saved search with this name already exists")
+)
+
+type SavedSearchService struct {
+	db        *gorm.DB
+	bookmarks *BookmarkService
+}
+
+func NewSavedSearchService(db *gorm.DB) *SavedSearchService {
+	return &SavedSearchService{db: db, bookmarks: NewBookmarkService(db)}
+}
+
+// SavedSearchQuery turns a saved search into the filter and sort used by
+// the bookmark list, validating them the same way as the query parameters
+func SavedSearchQuery(search *models.SavedSearch) (BookmarkFilter, BookmarkSort, error) {
+	tagMode, err := ParseTagMode(search.TagMode)
+	if err != nil {
+		return BookmarkFilter{}, BookmarkSort{}, fmt.Errorf("%w: %v", ErrInvalidSavedSearch, err)
+	}
+	sort, err := ParseSort(search.Sort, search.Order)
+	if err != nil {
+		return BookmarkFilter{}, BookmarkSort{}, fmt.Errorf("%w: %v", ErrInvalidSavedSearch, err)
+	}
+	if _, err := searchquery.Parse(search.Search); err != nil {
+		return BookmarkFilter{}, BookmarkSort{}, fmt.Errorf("%w: %v", ErrInvalidSavedSearch, err)
+	}
+
+	filter := BookmarkFilter{
+		Search:   search.Search,
+		Fuzzy:    search.Fuzzy,
+		Archived: search.Archived,
+		TagMode:  tagMode,
+		Untagged: search.Untagged,
+	}
+	for _, tag := range search.Tags {
+		filter.Tags = append(filter.Tags, ParseTagCondition(tag))
+	}
+	for _, tag := range search.ExcludeTags {
+		filter.ExcludeTags = append(filter.ExcludeTags, ParseTagCondition(tag))
+	}
+	return filter, sort, nil
+}
+
+// List returns every saved search by name
+func (s *SavedSearchService) List() ([]models.SavedSearch, error) {
+	searches := []models.SavedSearch{}
+	if err := s.db.Order("name").Find(&searches).Error; err != nil {
+		return nil, err
+	}
+	return searches, nil
+}
+
+func (s *SavedSearchService) Get(id uint) (*models.SavedSearch, error) {
+	var search models.SavedSearch
+	if err := s.db.First(&search, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &search, nil
+}
+
+// Create validates and stores a new saved search
+func (s *SavedSearchService) Create(search *models.SavedSearch) error {
+	if err := s.validate(search); err != nil {
+		return err
+	}
+	return s.db.Create(search).Error
+}
+
+// Update replaces the name and filters of a saved search
+func (s *SavedSearchService) Update(id uint, input *models.SavedSearch) (*models.SavedSearch, error) {
+	search, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	input.ID = search.ID
+	input.CreatedAt = search.CreatedAt
+	if err := s.validate(input); err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(input).Error; err != nil {
+		return nil, err
+	}
+	return input, nil
+}
+
+func (s *SavedSearchService) Delete(id uint) error {
+	result := s.db.Delete(&models.SavedSearch{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Count returns how many bookmarks currently match a saved search
+func (s *SavedSearchService) Count(search *models.SavedSearch) (int64, error) {
+	filter, _, err := SavedSearchQuery(search)
+	if err != nil {
+		return 0, err
+	}
+	return s.bookmarks.Count(filter)
+}
+
+// Counts returns how many bookmarks currently match each saved search, in
+// one query; see BookmarkService.CountEach for its cost
+func (s *SavedSearchService) Counts(searches []models.SavedSearch) ([]int64, error) {
+	filters := make([]BookmarkFilter, len(searches))
+	for i := range searches {
+		filter, _, err := SavedSearchQuery(&searches[i])
+		if err != nil {
+			return nil, err
+		}
+		filters[i] = filter
+	}
+	return s.bookmarks.CountEach(filters)
+}
+
+func (s *SavedSearchService) validate(search *models.SavedSearch) error {
+	if search.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSavedSearch)
+	}
+	if _, _, err := SavedSearchQuery(search); err != nil {
+		return err
+	}
+
+	var taken int64
+	if err := s.db.Model(&models.SavedSearch{}).
+		Where("name = ? AND id <> ?", search.Name, search.ID).
+		Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrSavedSearchExists
+	}
+	return nil
+}
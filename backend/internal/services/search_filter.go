@@ -0,0 +1,65 @@
+package services
+
+import (
+	"encoding/json"
+
+	"github.com/helioLJ/tweetvault/internal/searchquery"
+	"gorm.io/gorm"
+)
+
+// applySearchFilters adds the operator terms of a parsed search to a query
+// on the materialized view. Negated operators are wrapped in NOT.
+func applySearchFilters(query *gorm.DB, filters []*searchquery.Filter, hierarchy *tagHierarchy) *gorm.DB {
+	for _, filter := range filters {
+		condition, args := searchFilterCondition(filter, hierarchy)
+		if filter.Negated {
+			condition = "NOT (" + condition + ")"
+		}
+		query = query.Where(condition, args...)
+	}
+	return query
+}
+
+func searchFilterCondition(filter *searchquery.Filter, hierarchy *tagHierarchy) (string, []interface{}) {
+	switch filter.Key {
+	case searchquery.KeyFrom:
+		return "LOWER(screen_name) = LOWER(?)", []interface{}{filter.Value}
+	case searchquery.KeyTag:
+		return tagMatch(TagCondition{Name: filter.Value}, hierarchy)
+	case searchquery.KeyHas:
+		switch filter.Value {
+		case "media":
+			return "json_array_length(media_json) > 0", nil
+		case "gif":
+			return "media_json::jsonb @> ?", []interface{}{mediaContains("animated_gif")}
+		default:
+			return "media_json::jsonb @> ?", []interface{}{mediaContains(filter.Value)}
+		}
+	case searchquery.KeyIs:
+		switch filter.Value {
+		case "archived":
+			return "archived", nil
+		case "reply":
+			return "COALESCE(in_reply_to, '') <> ''", nil
+		default:
+			return "COALESCE(quoted_status, '') <> ''", nil
+		}
+	case searchquery.KeyBefore:
+		return "created_at < ?", []interface{}{filter.Date}
+	case searchquery.KeyAfter:
+		// Like before:, after: excludes the given day itself
+		return "created_at >= ?", []interface{}{filter.Date.AddDate(0, 0, 1)}
+	case searchquery.KeyMinFaves:
+		return "favorite_count >= ?", []interface{}{filter.Number}
+	case searchquery.KeyMinRetweets:
+		return "retweet_count >= ?", []interface{}{filter.Number}
+	default:
+		return "views_count >= ?", []interface{}{filter.Number}
+	}
+}
+
+// mediaContains builds the JSON array that matches a media type in media_json with @>
+func mediaContains(mediaType string) string {
+	data, _ := json.Marshal([]map[string]string{{"type": mediaType}})
+	return string(data)
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/helioLJ/tweetvault/internal/searchquery"
+)
+
+// before: and after: both leave out the named day, like comparing dates
+// strictly: after:2024-03-01 starts at March 2 and before:2024-03-01 ends at
+// February 29
+func TestDateFiltersExcludeTheNamedDay(t *testing.T) {
+	query, err := searchquery.Parse("after:2024-03-01 before:2024-03-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filters := query.Filters()
+
+	tests := []struct {
+		filter    *searchquery.Filter
+		condition string
+		bound     time.Time
+	}{
+		{filters[0], "created_at >= ?", time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{filters[1], "created_at < ?", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		condition, args := searchFilterCondition(tt.filter, nil)
+		if condition != tt.condition || !reflect.DeepEqual(args, []interface{}{tt.bound}) {
+			t.Errorf("%s:%s = %q %v, want %q [%v]", tt.filter.Key, tt.filter.Value, condition, args, tt.condition, tt.bound)
+		}
+	}
+}
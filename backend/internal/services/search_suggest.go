@@ -0,0 +1,105 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Suggestion count bounds per group
+const (
+	DefaultSuggestLimit = 5
+	MaxSuggestLimit     = 20
+)
+
+// Suggestions are the autocomplete matches for a partial search term
+type Suggestions struct {
+	Tags     []Suggestion       `json:"tags"`
+	Authors  []AuthorSuggestion `json:"authors"`
+	Hashtags []Suggestion       `json:"hashtags"`
+}
+
+// Suggestion is a tag or hashtag with the number of bookmarks it appears on
+type Suggestion struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// AuthorSuggestion is an author with the number of bookmarked tweets
+type AuthorSuggestion struct {
+	ScreenName      string `json:"screen_name"`
+	Name            string `json:"name"`
+	ProfileImageURL string `json:"profile_image_url"`
+	Count           int64  `json:"count"`
+}
+
+// ParseSuggestLimit validates the number of suggestions per group
+func ParseSuggestLimit(limit string) (int, error) {
+	if limit == "" {
+		return DefaultSuggestLimit, nil
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 1 || n > MaxSuggestLimit {
+		return 0, fmt.Errorf("invalid limit %q: must be between 1 and %d", limit, MaxSuggestLimit)
+	}
+	return n, nil
+}
+
+// Suggest returns tags, authors and hashtags matching a partial term. Prefix
+// matches come first, then near spellings by trigram similarity. A leading
+// '#' or '@' is ignored.
+func (s *BookmarkService) Suggest(term string, limit int) (*Suggestions, error) {
+	suggestions := &Suggestions{Tags: []Suggestion{}, Authors: []AuthorSuggestion{}, Hashtags: []Suggestion{}}
+	term = strings.TrimLeft(strings.TrimSpace(term), "#@")
+	if term == "" {
+		return suggestions, nil
+	}
+	prefix := escapeLike(strings.ToLower(term)) + "%"
+
+	if err := s.db.Raw(`
+		SELECT t.name AS value, COUNT(bt.bookmark_id) AS count
+		FROM tags t
+		LEFT JOIN bookmark_tags bt ON bt.tag_id = t.id
+		WHERE LOWER(t.name) LIKE ? OR t.name % ?
+		GROUP BY t.id, t.name
+		ORDER BY LOWER(t.name) LIKE ? DESC, similarity(t.name, ?) DESC, count DESC, t.name
+		LIMIT ?
+	`, prefix, term, prefix, term, limit).Scan(&suggestions.Tags).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Raw(`
+		SELECT screen_name, MAX(name) AS name, MAX(profile_image_url) AS profile_image_url, COUNT(*) AS count
+		FROM bookmark_views
+		WHERE LOWER(screen_name) LIKE ? OR LOWER(name) LIKE ? OR screen_name % ? OR name % ?
+		GROUP BY screen_name
+		ORDER BY BOOL_OR(LOWER(screen_name) LIKE ? OR LOWER(name) LIKE ?) DESC,
+			MAX(GREATEST(similarity(screen_name, ?), similarity(name, ?))) DESC,
+			count DESC, screen_name
+		LIMIT ?
+	`, prefix, prefix, term, term, prefix, prefix, term, term, limit).Scan(&suggestions.Authors).Error; err != nil {
+		return nil, err
+	}
+
+	// Hashtags are only completed by prefix; they are short and mostly typed in full
+	if err := s.db.Raw(`
+		SELECT h AS value, COUNT(*) AS count
+		FROM bookmark_views, unnest(hashtags) AS h
+		WHERE h LIKE ?
+		GROUP BY h
+		ORDER BY count DESC, h
+		LIMIT ?
+	`, prefix, limit).Scan(&suggestions.Hashtags).Error; err != nil {
+		return nil, err
+	}
+
+	suggestions.Tags = nonNil(suggestions.Tags)
+	suggestions.Authors = nonNil(suggestions.Authors)
+	suggestions.Hashtags = nonNil(suggestions.Hashtags)
+	return suggestions, nil
+}
+
+// escapeLike escapes the LIKE wildcards in a literal pattern
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
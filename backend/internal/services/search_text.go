@@ -0,0 +1,55 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/helioLJ/tweetvault/internal/searchquery"
+	"gorm.io/gorm"
+)
+
+// textSearch is the free text of a search and how it is matched: with
+// full-text search, or in fuzzy mode by trigram similarity
+type textSearch struct {
+	text  string
+	fuzzy bool
+}
+
+// newTextSearch reads the text terms of a parsed search. Fuzzy searches
+// match the words as typed, so they aren't rendered in websearch syntax.
+func newTextSearch(parsed *searchquery.Query, fuzzy bool) textSearch {
+	if fuzzy {
+		return textSearch{text: strings.Join(parsed.Words(false), " "), fuzzy: true}
+	}
+	return textSearch{text: parsed.FullText()}
+}
+
+// apply keeps the matching rows. Fuzzy searches match near spellings of
+// author names, handles and words of the tweet text using the pg_trgm
+// operators, which the trigram indexes on the view support.
+func (t textSearch) apply(query *gorm.DB, parsed *searchquery.Query) *gorm.DB {
+	if !t.fuzzy {
+		if t.text != "" {
+			query = query.Where("search_vector @@ websearch_to_tsquery('english', ?)", t.text)
+		}
+		return query
+	}
+
+	if t.text != "" {
+		query = query.Where("(screen_name % ? OR name % ? OR ? <% full_text)", t.text, t.text, t.text)
+	}
+	// Excluded words still have to be left out exactly
+	for _, word := range parsed.Words(true) {
+		query = query.Where("NOT (search_vector @@ phraseto_tsquery('english', ?))", word)
+	}
+	return query
+}
+
+// rank is the relevance score the results are ordered by: the full-text rank,
+// or the best similarity to an author field or part of the text
+func (t textSearch) rank() (string, []interface{}) {
+	if t.fuzzy {
+		return "GREATEST(similarity(screen_name, ?), similarity(name, ?), word_similarity(?, full_text))",
+			[]interface{}{t.text, t.text, t.text}
+	}
+	return "ts_rank(search_vector, websearch_to_tsquery('english', ?))", []interface{}{t.text}
+}
@@ -0,0 +1,488 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/helioLJ/tweetvault/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidTagParent is returned for parents that don't exist or would
+	// make a tag its own ancestor
+	ErrInvalidTagParent = errors.New("invalid tag parent")
+	// ErrInvalidMerge is returned when merging a tag into itself
+	ErrInvalidMerge = errors.New("a tag can't be merged into itself")
+	// ErrInvalidTagName wraps validation errors of tag names
+	ErrInvalidTagName = errors.New("invalid tag name")
+	// ErrInvalidTagMetadata wraps validation errors of tag colors, icons,
+	// descriptions and positions
+	ErrInvalidTagMetadata = errors.New("invalid tag metadata")
+)
+
+// TagOrder sorts tags for the sidebar: pinned tags by position, then the
+// rest by name
+const TagOrder = "position NULLS LAST, LOWER(name)"
+
+// TagTreeSQL is a recursive CTE named tag_tree that pairs every tag
+// (root_id) with itself and each of its descendants (id), for rolling
+// counts up the hierarchy
+const TagTreeSQL = `
+	WITH RECURSIVE tag_tree AS (
+		SELECT id AS root_id, id FROM tags
+		UNION
+		SELECT tag_tree.root_id, t.id FROM tags t JOIN tag_tree ON t.parent_id = tag_tree.id
+	)`
+
+// Limits of the tag columns
+const (
+	maxTagNameLength        = 50
+	maxTagIconLength        = 32
+	maxTagDescriptionLength = 500
+)
+
+// tagColor matches a "#rrggbb" hex color
+var tagColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type TagService struct {
+	db *gorm.DB
+}
+
+func NewTagService(db *gorm.DB) *TagService {
+	return &TagService{db: db}
+}
+
+// TagNode is a tag in the tag tree. Count is the number of bookmarks with
+// the tag itself; TotalCount also includes those with any descendant.
+type TagNode struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	ParentID   *uint      `json:"parent_id"`
+	Count      int64      `json:"count"`
+	TotalCount int64      `json:"total_count"`
+	Children   []*TagNode `json:"children"`
+
+	models.TagMetadata
+}
+
+// Tree returns the root tags with their descendants, each level in TagOrder
+func (s *TagService) Tree() ([]*TagNode, error) {
+	var tags []models.Tag
+	if err := s.db.Order(TagOrder).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		ID         uint
+		Count      int64
+		TotalCount int64
+	}
+	if err := s.db.Raw(TagTreeSQL + `
+		SELECT tag_tree.root_id AS id,
+			COUNT(DISTINCT bt.bookmark_id) FILTER (WHERE bt.tag_id = tag_tree.root_id) AS count,
+			COUNT(DISTINCT bt.bookmark_id) AS total_count
+		FROM tag_tree
+		LEFT JOIN bookmark_tags bt ON bt.tag_id = tag_tree.id
+		GROUP BY tag_tree.root_id
+	`).Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[uint]*TagNode, len(tags))
+	for _, tag := range tags {
+		nodes[tag.ID] = &TagNode{ID: tag.ID, Name: tag.Name, ParentID: tag.ParentID, TagMetadata: tag.TagMetadata, Children: []*TagNode{}}
+	}
+	for _, count := range counts {
+		if node, ok := nodes[count.ID]; ok {
+			node.Count = count.Count
+			node.TotalCount = count.TotalCount
+		}
+	}
+
+	roots := []*TagNode{}
+	for _, tag := range tags {
+		node := nodes[tag.ID]
+		if tag.ParentID != nil {
+			if parent, ok := nodes[*tag.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots, nil
+}
+
+// Move puts a tag and its subtree under a new parent, or at the root when
+// parentID is nil. Parents inside the subtree are rejected.
+func (s *TagService) Move(id uint, parentID *uint) (*models.Tag, error) {
+	var tag models.Tag
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Serialize moves so two of them can't form a cycle together
+		if err := tx.Exec("LOCK TABLE tags IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+		if err := tx.First(&tag, id).Error; err != nil {
+			return err
+		}
+		if err := checkTagParent(tx, id, parentID); err != nil {
+			return err
+		}
+
+		tag.ParentID = parentID
+		return tx.Model(&tag).Update("parent_id", parentID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// Merge moves every bookmark of the source tag to the target tag and
+// deletes the source. Bookmarks with both tags keep a single link, completed
+// if either was. The source's children move under the target, unless the
+// target is one of them or their descendants, in which case they move up to
+// the source's parent. With alias set, the source name becomes an alias of
+// the target, as do the source's own aliases either way.
+func (s *TagService) Merge(sourceID, targetID uint, alias bool) (*models.Tag, error) {
+	if sourceID == targetID {
+		return nil, ErrInvalidMerge
+	}
+
+	var target models.Tag
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Hold off concurrent moves and merges while the tree changes
+		if err := tx.Exec("LOCK TABLE tags IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+
+		var source models.Tag
+		if err := tx.First(&source, sourceID).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&target, targetID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec(`
+			UPDATE bookmark_tags target SET completed = target.completed OR source.completed
+			FROM bookmark_tags source
+			WHERE source.tag_id = ? AND target.tag_id = ? AND target.bookmark_id = source.bookmark_id
+		`, source.ID, target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`
+			INSERT INTO bookmark_tags (bookmark_id, tag_id, created_at, completed)
+			SELECT bookmark_id, ?, created_at, completed FROM bookmark_tags WHERE tag_id = ?
+			ON CONFLICT DO NOTHING
+		`, target.ID, source.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id = ?", source.ID).Delete(&models.BookmarkTag{}).Error; err != nil {
+			return err
+		}
+
+		newParent := &target.ID
+		if checkTagParent(tx, source.ID, &target.ID) != nil {
+			newParent = source.ParentID
+		}
+		if err := tx.Model(&models.Tag{}).Where("parent_id = ?", source.ID).Update("parent_id", newParent).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.TagAlias{}).Where("tag_id = ?", source.ID).Update("tag_id", target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&source).Error; err != nil {
+			return err
+		}
+		if alias {
+			if err := tx.Create(&models.TagAlias{Name: source.Name, TagID: target.ID}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Preload("Aliases").First(&target, target.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+// resolveTagAliases replaces aliases in a list of tag names with the names
+// of their tags, ignoring case, and drops the duplicates that leaves
+func resolveTagAliases(tx *gorm.DB, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return names, nil
+	}
+
+	lowered := make([]string, len(names))
+	for i, name := range names {
+		lowered[i] = strings.ToLower(name)
+	}
+
+	var aliases []struct {
+		Alias string
+		Name  string
+	}
+	if err := tx.Table("tag_aliases").
+		Select("tag_aliases.name AS alias, tags.name AS name").
+		Joins("JOIN tags ON tags.id = tag_aliases.tag_id").
+		Where("LOWER(tag_aliases.name) IN ?", lowered).
+		Scan(&aliases).Error; err != nil {
+		return nil, err
+	}
+	canonical := make(map[string]string, len(aliases))
+	for _, alias := range aliases {
+		canonical[strings.ToLower(alias.Alias)] = alias.Name
+	}
+
+	resolved := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if tagName, ok := canonical[strings.ToLower(name)]; ok {
+			name = tagName
+		}
+		if key := strings.ToLower(name); !seen[key] {
+			seen[key] = true
+			resolved = append(resolved, name)
+		}
+	}
+	return resolved, nil
+}
+
+// ValidateTagName normalizes a tag name with models.NormalizeTagName and
+// checks that it fits the tags table and has no ':', which filters reserve
+func ValidateTagName(name string) (string, error) {
+	name = models.NormalizeTagName(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name is required", ErrInvalidTagName)
+	}
+	if utf8.RuneCountInString(name) > maxTagNameLength {
+		return "", fmt.Errorf("%w: %q is longer than %d characters", ErrInvalidTagName, name, maxTagNameLength)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return "", fmt.Errorf("%w: %q contains control characters", ErrInvalidTagName, name)
+		}
+	}
+	if strings.Contains(name, ":") {
+		return "", fmt.Errorf("%w: %q contains ':'", ErrInvalidTagName, name)
+	}
+	return name, nil
+}
+
+// ValidateTagMetadata trims the metadata of a tag and checks that the color
+// is a hex color, the icon and description fit and the position isn't
+// negative
+func ValidateTagMetadata(metadata models.TagMetadata) (models.TagMetadata, error) {
+	metadata.Color = strings.ToLower(strings.TrimSpace(metadata.Color))
+	metadata.Icon = strings.TrimSpace(metadata.Icon)
+	metadata.Description = strings.TrimSpace(metadata.Description)
+
+	if metadata.Color != "" && !tagColor.MatchString(metadata.Color) {
+		return metadata, fmt.Errorf("%w: color %q must be a #rrggbb hex color", ErrInvalidTagMetadata, metadata.Color)
+	}
+	if utf8.RuneCountInString(metadata.Icon) > maxTagIconLength {
+		return metadata, fmt.Errorf("%w: icon is longer than %d characters", ErrInvalidTagMetadata, maxTagIconLength)
+	}
+	if utf8.RuneCountInString(metadata.Description) > maxTagDescriptionLength {
+		return metadata, fmt.Errorf("%w: description is longer than %d characters", ErrInvalidTagMetadata, maxTagDescriptionLength)
+	}
+	if metadata.Position != nil && *metadata.Position < 0 {
+		return metadata, fmt.Errorf("%w: position can't be negative", ErrInvalidTagMetadata)
+	}
+	return metadata, nil
+}
+
+// TagMetadataUpdate changes the metadata fields present in a request and
+// keeps the others. Position is kept raw so an explicit null, which unpins
+// the tag, differs from leaving it out.
+type TagMetadataUpdate struct {
+	Color       *string         `json:"color"`
+	Icon        *string         `json:"icon"`
+	Description *string         `json:"description"`
+	Position    json.RawMessage `json:"position"`
+}
+
+// Apply returns the metadata with the update applied, validated with
+// ValidateTagMetadata
+func (u TagMetadataUpdate) Apply(metadata models.TagMetadata) (models.TagMetadata, error) {
+	if u.Color != nil {
+		metadata.Color = *u.Color
+	}
+	if u.Icon != nil {
+		metadata.Icon = *u.Icon
+	}
+	if u.Description != nil {
+		metadata.Description = *u.Description
+	}
+	if len(u.Position) > 0 {
+		metadata.Position = nil
+		if !bytes.Equal(u.Position, []byte("null")) {
+			var position int
+			if err := json.Unmarshal(u.Position, &position); err != nil {
+				return metadata, fmt.Errorf("%w: position must be an integer or null", ErrInvalidTagMetadata)
+			}
+			metadata.Position = &position
+		}
+	}
+	return ValidateTagMetadata(metadata)
+}
+
+// normalizeTagNames validates a list of tag names, dropping the ones that
+// repeat an earlier name ignoring case
+func normalizeTagNames(names []string) ([]string, error) {
+	normalized := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name, err := ValidateTagName(name)
+		if err != nil {
+			return nil, err
+		}
+		if key := strings.ToLower(name); !seen[key] {
+			seen[key] = true
+			normalized = append(normalized, name)
+		}
+	}
+	return normalized, nil
+}
+
+// checkTagParent verifies that parentID exists and isn't the tag itself or
+// one of its descendants. A tag being created passes an id of 0.
+func checkTagParent(tx *gorm.DB, id uint, parentID *uint) error {
+	if parentID == nil {
+		return nil
+	}
+
+	var parents []models.Tag
+	if err := tx.Select("id", "parent_id").Find(&parents).Error; err != nil {
+		return err
+	}
+	parentOf := make(map[uint]*uint, len(parents))
+	for _, tag := range parents {
+		parentOf[tag.ID] = tag.ParentID
+	}
+
+	if _, ok := parentOf[*parentID]; !ok {
+		return fmt.Errorf("%w: tag %d does not exist", ErrInvalidTagParent, *parentID)
+	}
+	for ancestor := parentID; ancestor != nil; ancestor = parentOf[*ancestor] {
+		if *ancestor == id {
+			return fmt.Errorf("%w: a tag can't be moved under itself or its descendants", ErrInvalidTagParent)
+		}
+	}
+	return nil
+}
+
+// CheckParent validates the parent of a new tag
+func (s *TagService) CheckParent(parentID *uint) error {
+	return checkTagParent(s.db, 0, parentID)
+}
+
+// tagHierarchy maps tag names to the names of their child tags, and lower
+// case tag and alias names to the stored tag names, so filters match tags
+// ignoring case and by any of their aliases
+type tagHierarchy struct {
+	children map[string][]string
+	names    map[string]string
+}
+
+func loadTagHierarchy(db *gorm.DB) (*tagHierarchy, error) {
+	var tags []struct {
+		Name   string
+		Parent *string
+	}
+	if err := db.Table("tags child").
+		Select("child.name AS name, parent.name AS parent").
+		Joins("LEFT JOIN tags parent ON parent.id = child.parent_id").
+		Scan(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	hierarchy := &tagHierarchy{
+		children: make(map[string][]string),
+		names:    make(map[string]string, len(tags)),
+	}
+	for _, tag := range tags {
+		hierarchy.names[strings.ToLower(tag.Name)] = tag.Name
+		if tag.Parent != nil {
+			hierarchy.children[*tag.Parent] = append(hierarchy.children[*tag.Parent], tag.Name)
+		}
+	}
+	for _, children := range hierarchy.children {
+		sort.Strings(children)
+	}
+
+	var aliases []struct {
+		Name string
+		Tag  string
+	}
+	if err := db.Table("tag_aliases").
+		Select("tag_aliases.name AS name, tags.name AS tag").
+		Joins("JOIN tags ON tags.id = tag_aliases.tag_id").
+		Scan(&aliases).Error; err != nil {
+		return nil, err
+	}
+	for _, alias := range aliases {
+		key := strings.ToLower(alias.Name)
+		if _, taken := hierarchy.names[key]; !taken {
+			hierarchy.names[key] = alias.Tag
+		}
+	}
+	return hierarchy, nil
+}
+
+// lazyTagHierarchy returns a function that loads the tag hierarchy on its
+// first call and returns the same one afterwards
+func lazyTagHierarchy(db *gorm.DB) func() (*tagHierarchy, error) {
+	var hierarchy *tagHierarchy
+	return func() (*tagHierarchy, error) {
+		if hierarchy != nil {
+			return hierarchy, nil
+		}
+		var err error
+		hierarchy, err = loadTagHierarchy(db)
+		return hierarchy, err
+	}
+}
+
+// resolve returns the stored name of the tag a name or alias refers to,
+// ignoring case
+func (h *tagHierarchy) resolve(name string) (string, bool) {
+	stored, ok := h.names[strings.ToLower(models.NormalizeTagName(name))]
+	return stored, ok
+}
+
+// expand returns the stored name of a tag, or of the tag an alias points
+// to, followed by the names of all its descendants. Without a hierarchy it
+// returns the name as given.
+func (h *tagHierarchy) expand(name string) []string {
+	if h == nil {
+		return []string{name}
+	}
+	if stored, ok := h.resolve(name); ok {
+		name = stored
+	}
+
+	names := []string{name}
+	seen := map[string]bool{name: true}
+	for i := 0; i < len(names); i++ {
+		for _, child := range h.children[names[i]] {
+			if !seen[child] {
+				seen[child] = true
+				names = append(names, child)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/helioLJ/tweetvault/internal/models"
+)
+
+func TestTagHierarchyExpand(t *testing.T) {
+	hierarchy := &tagHierarchy{
+		children: map[string][]string{
+			"Reading": {"Books", "Papers"},
+			"Papers":  {"Preprints"},
+		},
+		names: map[string]string{
+			"reading":   "Reading",
+			"books":     "Books",
+			"papers":    "Papers",
+			"preprints": "Preprints",
+			"research":  "Papers", // Alias
+		},
+	}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"Reading", []string{"Reading", "Books", "Papers", "Preprints"}},
+		{"reading", []string{"Reading", "Books", "Papers", "Preprints"}},
+		{"Research", []string{"Papers", "Preprints"}},
+		{"  research ", []string{"Papers", "Preprints"}},
+		{"Preprints", []string{"Preprints"}},
+		{"Unknown", []string{"Unknown"}},
+	}
+	for _, tt := range tests {
+		if got := hierarchy.expand(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expand(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	var none *tagHierarchy
+	if got := none.expand("Research"); !reflect.DeepEqual(got, []string{"Research"}) {
+		t.Errorf("expand without a hierarchy = %q, want the name as given", got)
+	}
+}
+
+func TestTagMetadataUpdateApply(t *testing.T) {
+	position := 2
+	current := models.TagMetadata{Color: "#336699", Icon: "📚", Description: "Long reads", Position: &position}
+	three := 3
+
+	tests := []struct {
+		name string
+		body string
+		want models.TagMetadata
+	}{
+		{"empty keeps everything", `{}`, current},
+		{"name only keeps everything", `{"name": "Reading"}`, current},
+		{"one field", `{"color": " #AABBCC "}`,
+			models.TagMetadata{Color: "#aabbcc", Icon: "📚", Description: "Long reads", Position: &position}},
+		{"empty string clears", `{"icon": "", "description": ""}`,
+			models.TagMetadata{Color: "#336699", Position: &position}},
+		{"position", `{"position": 3}`,
+			models.TagMetadata{Color: "#336699", Icon: "📚", Description: "Long reads", Position: &three}},
+		{"null position unpins", `{"position": null}`,
+			models.TagMetadata{Color: "#336699", Icon: "📚", Description: "Long reads"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var update TagMetadataUpdate
+			if err := json.Unmarshal([]byte(tt.body), &update); err != nil {
+				t.Fatal(err)
+			}
+			got, err := update.Apply(current)
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply(%s) = %+v, want %+v", tt.body, got, tt.want)
+			}
+		})
+	}
+
+	for _, body := range []string{`{"position": "first"}`, `{"position": -1}`, `{"color": "red"}`} {
+		var update TagMetadataUpdate
+		if err := json.Unmarshal([]byte(body), &update); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := update.Apply(current); !errors.Is(err, ErrInvalidTagMetadata) {
+			t.Errorf("Apply(%s) error = %v, want ErrInvalidTagMetadata", body, err)
+		}
+	}
+}
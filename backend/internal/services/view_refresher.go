@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const viewRefresherName = "tweetvault:view_refresher"
+
+// maxViewWait bounds how long a list read waits for pending writes to reach
+// the materialized view before it reads stale rows
+const maxViewWait = time.Second
+
+// viewSources are the tables bookmark_views is built from
+var viewSources = map[string]bool{
+	"bookmarks":     true,
+	"media":         true,
+	"tags":          true,
+	"bookmark_tags": true,
+}
+
+// rawViewWrite matches raw SQL statements that write to a view source
+var rawViewWrite = regexp.MustCompile(`(?is)^\s*(INSERT\s+INTO|UPDATE|DELETE\s+FROM)\s+"?(bookmarks|media|tags|bookmark_tags)\b`)
+
+// ViewRefresher is a GORM plugin that keeps bookmark_views in step with
+// writes. Writes to the base tables mark the view dirty, and a concurrent
+// refresh runs once they have been quiet for the debounce delay, or at the
+// latest maxDelay after the first pending write, so sustained writes such as
+// imports still reach the view. Requests that read the view wait for pending
+// refreshes with WaitForView, so a list fetched right after tagging or
+// archiving shows the change.
+//
+// Writes inside a transaction mark the view when the transaction commits,
+// so a refresh never runs before their rows are visible.
+type ViewRefresher struct {
+	db       *gorm.DB
+	delay    time.Duration
+	maxDelay time.Duration
+
+	mu           sync.Mutex
+	changed      uint64        // Writes seen
+	refreshed    uint64        // Writes covered by the last refresh
+	refreshing   bool          // A refresh is running
+	pendingSince time.Time     // First write not yet being refreshed
+	timer        *time.Timer   // Pending debounced refresh
+	done         chan struct{} // Closed when the running refresh finishes
+}
+
+// NewViewRefresher returns the plugin, to be installed with db.Use
+func NewViewRefresher(delay, maxDelay time.Duration) *ViewRefresher {
+	return &ViewRefresher{delay: delay, maxDelay: maxDelay, done: make(chan struct{})}
+}
+
+func (r *ViewRefresher) Name() string {
+	return viewRefresherName
+}
+
+// Initialize registers the callbacks that watch for writes, and wraps the
+// connection pool so transactions report their writes on commit
+func (r *ViewRefresher) Initialize(db *gorm.DB) error {
+	r.db = db
+
+	pool := &viewPool{ConnPool: db.Statement.ConnPool, refresher: r}
+	db.ConnPool = pool
+	db.Statement.ConnPool = pool
+
+	callbacks := db.Callback()
+	if err := callbacks.Create().After("gorm:create").Register(viewRefresherName, r.afterWrite); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register(viewRefresherName, r.afterWrite); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().After("gorm:delete").Register(viewRefresherName, r.afterWrite); err != nil {
+		return err
+	}
+	return callbacks.Raw().After("gorm:raw").Register(viewRefresherName, r.afterWrite)
+}
+
+func (r *ViewRefresher) afterWrite(db *gorm.DB) {
+	if db.Error != nil || db.DryRun || db.Statement.RowsAffected == 0 {
+		return
+	}
+	if !viewSources[db.Statement.Table] && !rawViewWrite.MatchString(db.Statement.SQL.String()) {
+		return
+	}
+	if tx, ok := db.Statement.ConnPool.(*viewTx); ok {
+		tx.dirty = true
+		return
+	}
+	r.MarkDirty()
+}
+
+// MarkDirty schedules a refresh, postponing one that is already pending
+// unless it has waited for maxDelay
+func (r *ViewRefresher) MarkDirty() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.changed++
+	if r.pendingSince.IsZero() {
+		r.pendingSince = time.Now()
+	}
+	r.schedule()
+}
+
+// schedule (re)starts the debounce timer. Called with mu held.
+func (r *ViewRefresher) schedule() {
+	wait := r.delay
+	if left := r.maxDelay - time.Since(r.pendingSince); left < wait {
+		wait = left
+	}
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.timer = time.AfterFunc(wait, r.refresh)
+}
+
+// refresh brings the view up to date with the writes seen so far. Only one
+// refresh runs at a time; writes that arrive meanwhile schedule another.
+func (r *ViewRefresher) refresh() {
+	r.mu.Lock()
+	if r.refreshing || r.refreshed == r.changed {
+		r.mu.Unlock()
+		return
+	}
+	r.refreshing = true
+	target := r.changed
+	r.pendingSince = time.Time{}
+	r.mu.Unlock()
+
+	if err := NewBookmarkService(r.db).RefreshView(); err != nil {
+		// Don't hold up reads; the periodic refresh job retries
+		log.Printf("Error refreshing materialized view: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refreshed = target
+	r.refreshing = false
+	close(r.done)
+	r.done = make(chan struct{})
+	if r.changed != r.refreshed {
+		r.schedule()
+	}
+}
+
+// Wait blocks until the writes seen so far have reached the view, or the
+// timeout passes. It reports whether the view is up to date.
+func (r *ViewRefresher) Wait(timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	r.mu.Lock()
+	target := r.changed
+	for r.refreshed < target {
+		done := r.done
+		r.mu.Unlock()
+		select {
+		case <-done:
+		case <-deadline.C:
+			return false
+		}
+		r.mu.Lock()
+	}
+	r.mu.Unlock()
+	return true
+}
+
+// WaitForView waits for pending writes to reach the view when the
+// ViewRefresher plugin is installed. Requests that read the view call it
+// once before their first query.
+func WaitForView(db *gorm.DB) {
+	refresher, ok := db.Config.Plugins[viewRefresherName].(*ViewRefresher)
+	if !ok {
+		return
+	}
+	if !refresher.Wait(maxViewWait) {
+		log.Printf("Materialized view is still refreshing, reading stale rows")
+	}
+}
+
+// viewPool wraps the connection pool so transactions begin as viewTx
+type viewPool struct {
+	gorm.ConnPool
+	refresher *ViewRefresher
+}
+
+func (p *viewPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	beginner, ok := p.ConnPool.(gorm.TxBeginner)
+	if !ok {
+		return nil, gorm.ErrInvalidTransaction
+	}
+	tx, err := beginner.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &viewTx{Tx: tx, pool: p}, nil
+}
+
+func (p *viewPool) GetDBConn() (*sql.DB, error) {
+	if db, ok := p.ConnPool.(*sql.DB); ok {
+		return db, nil
+	}
+	return nil, errors.New("view refresher: connection pool is not a *sql.DB")
+}
+
+// viewTx is a transaction that marks the view dirty when it commits, if any
+// of its statements wrote to a view source
+type viewTx struct {
+	*sql.Tx
+	pool  *viewPool
+	dirty bool
+}
+
+func (tx *viewTx) Commit() error {
+	if err := tx.Tx.Commit(); err != nil {
+		return err
+	}
+	if tx.dirty {
+		tx.pool.refresher.MarkDirty()
+	}
+	return nil
+}
+
+func (tx *viewTx) GetDBConn() (*sql.DB, error) {
+	return tx.pool.GetDBConn()
+}
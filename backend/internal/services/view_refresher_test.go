@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestViewRefresherWaitsForCommit(t *testing.T) {
+	db, conn := refresherDB(t, 20*time.Millisecond, time.Second)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("UPDATE bookmarks SET archived = true").Error; err != nil {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+		if got := conn.refreshes(); got != 0 {
+			t.Errorf("%d refreshes before commit, want 0", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	WaitForView(db)
+	if got := conn.refreshes(); got != 1 {
+		t.Errorf("%d refreshes after commit, want 1", got)
+	}
+}
+
+func TestViewRefresherSkipsRolledBackWrites(t *testing.T) {
+	db, conn := refresherDB(t, 20*time.Millisecond, time.Second)
+
+	rollback := errors.New("rollback")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM bookmarks").Error; err != nil {
+			return err
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if got := conn.refreshes(); got != 0 {
+		t.Errorf("%d refreshes, want 0", got)
+	}
+}
+
+func TestViewRefresherMaxDelay(t *testing.T) {
+	db, conn := refresherDB(t, 50*time.Millisecond, 150*time.Millisecond)
+
+	// Writes closer together than the delay never let it expire
+	stop := time.Now().Add(500 * time.Millisecond)
+	for time.Now().Before(stop) {
+		if err := db.Exec("UPDATE bookmarks SET archived = false").Error; err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := conn.refreshes(); got < 2 {
+		t.Errorf("%d refreshes during sustained writes, want at least 2", got)
+	}
+}
+
+// refresherDB returns a database with the ViewRefresher installed, backed by
+// a driver that accepts every statement and records the refreshes
+func refresherDB(t *testing.T, delay, maxDelay time.Duration) (*gorm.DB, *fakeConn) {
+	t.Helper()
+
+	conn := &fakeConn{}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(conn)}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(NewViewRefresher(delay, maxDelay)); err != nil {
+		t.Fatal(err)
+	}
+	return db, conn
+}
+
+// fakeConn is a driver connection, and its own connector and driver, that
+// runs nothing
+type fakeConn struct {
+	mu      sync.Mutex
+	refresh int
+}
+
+func (c *fakeConn) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c *fakeConn) Driver() driver.Driver                        { return c }
+func (c *fakeConn) Open(string) (driver.Conn, error)             { return c, nil }
+
+func (c *fakeConn) refreshes() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refresh
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c, query}, nil }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if strings.HasPrefix(s.query, "REFRESH") {
+		s.conn.mu.Lock()
+		s.conn.refresh++
+		s.conn.mu.Unlock()
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) { return fakeRows{}, nil }
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string              { return nil }
+func (fakeRows) Close() error                   { return nil }
+func (fakeRows) Next(dest []driver.Value) error { return io.EOF }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }